@@ -4,7 +4,7 @@ import (
 	"log"
 
 	"open_tool_speex/internal/config"
-	"open_tool_speex/internal/processor"
+	"open_tool_speex/pkg/pipeline"
 )
 
 func main() {
@@ -14,13 +14,20 @@ func main() {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
-	// Create processor
-	proc := processor.NewProcessor(cfg)
+	// Build the processing pipeline
+	p, err := pipeline.New(*cfg)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
 
 	// Process audio
-	if err := proc.Process(); err != nil {
+	if err := p.Run(); err != nil {
 		log.Fatalf("Processing error: %v", err)
 	}
 
-	log.Printf("AEC processing completed: %s -> %s", cfg.MicFile, cfg.OutputFile)
+	input := cfg.MicFile
+	if cfg.InputFile != "" {
+		input = cfg.InputFile
+	}
+	log.Printf("AEC processing completed: %s -> %s", input, cfg.OutputFile)
 }
@@ -1,28 +1,98 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/stage"
 	"open_tool_speex/pkg/types"
 )
 
+// errMissingParameters reports a configuration without its input files
+var errMissingParameters = errors.New("missing required parameters")
+
 // ParseFlags parses command line flags and returns configuration
 func ParseFlags() (*types.Config, error) {
 	config := types.DefaultConfig()
 
 	var (
-		micFile        = flag.String("mic", "", "Path to microphone input file (raw A-law, 16kHz)")
-		speakerFile    = flag.String("speaker", "", "Path to speaker reference file (raw A-law, 16kHz)")
-		outputFile     = flag.String("output", config.OutputFile, "Path to output file (raw A-law, 16kHz)")
-		usePrevSpeaker = flag.Bool("prev-speaker", config.UsePrevSpeaker, "Use previous speaker frame with current mic frame (delay compensation)")
+		micFiles       = listFlag("mic", "Path to microphone input file (raw or WAV, - for stdin); repeat for multi-channel AEC")
+		speakerFiles   = listFlag("speaker", "Path to speaker reference file (raw or WAV, - for stdin); repeat for multi-channel AEC")
+		outputFile     = flag.String("output", config.OutputFile, "Path to output file (raw A-law, WAV if the name ends in .wav, - for stdout)")
+		usePrevSpeaker = flag.Bool("prev-speaker", config.UsePrevSpeaker, "Use previous speaker frame with current mic frame (same as -speaker-delay of one frame)")
+		speakerDelay   = flag.Int("speaker-delay", config.SpeakerDelay, "Delay the speaker stream by N samples (negative advances it by delaying the mic)")
+		speakerDelayMs = flag.Float64("speaker-delay-ms", config.SpeakerDelayMs, "Delay the speaker stream by N milliseconds (negative advances it)")
 		nsFirst        = flag.Bool("ns-first", false, "Apply Noise Suppression before Echo Cancellation (default: AEC then NS)")
 		nsOnly         = flag.Bool("ns-only", false, "Apply only Noise Suppression (no echo cancellation)")
 		aecOnly        = flag.Bool("aec-only", false, "Apply only Echo Cancellation (no noise suppression)")
 		bypass         = flag.Bool("bypass", false, "Bypass all processing (copy input to output for testing)")
 		testAlaw       = flag.Bool("test-alaw", false, "Test A-law encoding/decoding (A-law -> PCM -> A-law)")
 
+		// Processing chain
+		chain     = flag.String("chain", "", "Comma-separated processing stages run in order (e.g. hpf,aec,ns,agc): "+strings.Join(stage.Names(), ", "))
+		hpfCutoff = flag.Float64("hpf-cutoff", config.HighPassHz, "Cutoff frequency of the hpf stage in Hz")
+		gainDB    = flag.Float64("gain", config.GainDB, "Gain of the gain stage in dB")
+
+		// Automatic delay alignment
+		autoDelay      = flag.Bool("auto-delay", config.AutoDelay, "Estimate the speaker-to-mic delay (GCC-PHAT) and shift the speaker stream to match")
+		maxDelayMs     = flag.Int("max-delay", config.MaxDelayMs, "Largest speaker-to-mic delay searched by -auto-delay in milliseconds")
+		delayWindowSec = flag.Float64("delay-window", config.DelayWindowSec, "Analysis window of -auto-delay in seconds")
+		delayUpdateSec = flag.Float64("delay-update", config.DelayUpdateSec, "Re-estimate the delay every N seconds (0 = estimate once at start)")
+		driftComp      = flag.Bool("drift-comp", config.DriftCompensation, "Measure mic/speaker clock drift and resample the speaker stream to cancel it")
+		driftUpdateSec = flag.Float64("drift-update", config.DriftUpdateSec, "Drift measurement interval in seconds")
+
+		// Echo metrics
+		metricsFile = flag.String("metrics", "", "Write an echo metrics (ERLE, residual echo, activity) JSON summary to this file")
+		metricsCSV  = flag.String("metrics-csv", "", "Write per-frame echo metrics to this CSV file")
+
+		// Echo path impulse response
+		impulseFile = flag.String("impulse-response", "", "Write the echo path learned by the adaptive filter to this CSV (or .wav) file")
+		impulseSec  = flag.Float64("impulse-response-interval", 0, "Also write numbered impulse response snapshots every N seconds (0 = end of processing only)")
+
+		// Voice activity timeline
+		vadCSV    = flag.String("vad-csv", "", "Write the per-frame VAD decision and speech probability to this CSV file (enables -vad)")
+		vadJSON   = flag.String("vad-json", "", "Write the VAD timeline and speech segments to this JSON file (enables -vad)")
+		vadLabels = flag.String("vad-labels", "", "Write speech segments to this Audacity label file (enables -vad)")
+
+		// VAD-gated output
+		vadGate        = flag.String("vad-gate", "", "Replace frames without speech: silence, noise (comfort noise) or drop (enables -vad)")
+		comfortNoiseDB = flag.Float64("comfort-noise", config.ComfortNoiseDB, "Comfort noise level of -vad-gate noise in dBFS")
+		vadEditList    = flag.String("vad-edit-list", "", "Write the source time of the ranges kept by -vad-gate drop to this CSV file")
+
+		// Comfort noise
+		comfortFill        = flag.Bool("comfort-fill", config.ComfortFill, "Fill suppressed output frames with noise matching the mic background")
+		comfortNoiseOffset = flag.Float64("comfort-noise-offset", config.ComfortNoiseOffsetDB, "Comfort noise level relative to the background after noise suppression in dB")
+
+		// Speech segmentation
+		segmentDir   = flag.String("segment-dir", "", "Also write each VAD speech segment to its own file in this directory, with a segments.json manifest (enables -vad)")
+		segmentPadMs = flag.Int("segment-pad", config.SegmentPadMs, "Padding kept before and after each speech segment in milliseconds")
+		segmentMinMs = flag.Int("segment-min", config.SegmentMinMs, "Shortest speech segment kept in milliseconds (without padding)")
+		segmentGapMs = flag.Int("segment-max-gap", config.SegmentMaxGapMs, "Longest silence kept inside a speech segment in milliseconds")
+
+		// Interleaved input
+		inputFile     = flag.String("input", "", "Path to interleaved input file carrying mic and speaker channels (replaces -mic/-speaker, - for stdin)")
+		inputChannels = flag.Int("input-channels", config.InputChannels, "Channel count of a raw interleaved -input file (WAV uses its header)")
+		channelMap    = flag.String("channel-map", fmt.Sprintf("mic=%d,ref=%d", config.MicChannel, config.SpeakerChannel), "Channel map of the -input file (e.g. mic=0,ref=1; mic=0+1,ref=2+3 for multi-channel AEC)")
+		stereoOutput  = flag.Bool("stereo-output", config.StereoOutput, "Write stereo output: processed mic (left) and original reference (right)")
+
+		// Sample formats
+		format        = flag.String("format", config.OutputFormat.String(), "Sample format of raw mic, speaker and output files: "+strings.Join(audio.CodecNames(), ", "))
+		micFormat     = flag.String("mic-format", "", "Sample format of the mic file (overrides -format)")
+		speakerFormat = flag.String("speaker-format", "", "Sample format of the speaker file (overrides -format)")
+		outputFormat  = flag.String("output-format", "", "Sample format of the output file (overrides -format)")
+
+		// Stream sample rates
+		micRate         = flag.Int("mic-rate", 0, "Sample rate of a raw mic file in Hz (0 = -sample-rate; WAV uses its header)")
+		speakerRate     = flag.Int("speaker-rate", 0, "Sample rate of a raw speaker file in Hz (0 = -sample-rate; WAV uses its header)")
+		outputRate      = flag.Int("output-rate", 0, "Sample rate of the output file in Hz (0 = -sample-rate)")
+		resampleQuality = flag.Int("resample-quality", config.ResampleQuality, "Resampler quality 0-10 (higher is better and slower)")
+
 		// Processing parameters (override defaults)
 		sampleRate  = flag.Int("sample-rate", config.SampleRate, "Sample rate in Hz (e.g., 16000)")
 		frameSize   = flag.Int("frame-size", config.FrameSize, "Frame size in samples (e.g., 320 for 20ms @16k)")
@@ -31,6 +101,7 @@ func ParseFlags() (*types.Config, error) {
 		progressSec = flag.Float64("progress-sec", config.ProgressSec, "Progress log interval in seconds (0 disables)")
 
 		// Noise Suppression parameters
+		denoise       = flag.Bool("denoise", config.NS.EnableDenoise, "Enable noise suppression (use -denoise=false to keep only VAD/AGC/dereverb)")
 		noiseSuppress = flag.Float64("noise-suppress", config.NS.NoiseSuppress, "Noise suppression level in dB (more negative = more suppression)")
 		enableVAD     = flag.Bool("vad", config.NS.EnableVAD, "Enable Voice Activity Detection")
 		vadProbStart  = flag.Int("vad-prob-start", config.NS.VADProbStart, "VAD probability threshold for speech start (0-100)")
@@ -38,16 +109,45 @@ func ParseFlags() (*types.Config, error) {
 		enableAGC     = flag.Bool("agc", config.NS.EnableAGC, "Enable Automatic Gain Control")
 		agcLevel      = flag.Float64("agc-level", config.NS.AGCLevel, "AGC target RMS level")
 
+		// AGC dynamics
+		agcIncrement = flag.Int("agc-increment", config.NS.AGCIncrement, "Maximal AGC gain increase in dB/second")
+		agcDecrement = flag.Int("agc-decrement", config.NS.AGCDecrement, "Maximal AGC gain decrease in dB/second, negative")
+		agcMaxGain   = flag.Int("agc-max-gain", config.NS.AGCMaxGain, "Maximal AGC gain in dB (0 = no gain)")
+		agcTarget    = flag.Int("agc-target", config.NS.AGCTarget, "Integer AGC target level, positive; overrides -agc-level when given")
+
+		// Dereverberation
+		dereverb      = flag.Bool("dereverb", config.NS.EnableDereverb, "Enable dereverberation")
+		dereverbLevel = flag.Float64("dereverb-level", config.NS.DereverbLevel, "Dereverberation level 0-1")
+		dereverbDecay = flag.Float64("dereverb-decay", config.NS.DereverbDecay, "Dereverberation decay 0-1")
+
+		// Residual echo suppression
+		echoSuppress       = flag.Int("echo-suppress", config.NS.EchoSuppress, "Residual echo attenuation in dB (-100 to 0, more negative = more suppression, 0 = none)")
+		echoSuppressActive = flag.Int("echo-suppress-active", config.NS.EchoSuppressActive, "Residual echo attenuation in dB while the near end talks (-100 to 0, 0 = none)")
+
 		help = flag.Bool("help", false, "Show help")
 	)
 
 	flag.Parse()
 
 	// Set file paths
-	config.MicFile = *micFile
-	config.SpeakerFile = *speakerFile
+	if len(*micFiles) > 0 {
+		config.MicFile = (*micFiles)[0]
+		config.ExtraMicFiles = (*micFiles)[1:]
+	}
+	if len(*speakerFiles) > 0 {
+		config.SpeakerFile = (*speakerFiles)[0]
+		config.ExtraSpeakerFiles = (*speakerFiles)[1:]
+	}
 	config.OutputFile = *outputFile
 
+	// Set interleaved input parameters
+	config.InputFile = *inputFile
+	config.InputChannels = *inputChannels
+	config.StereoOutput = *stereoOutput
+	if err := parseChannelMap(&config, *channelMap); err != nil {
+		return nil, err
+	}
+
 	// Determine processing mode
 	exclusiveCount := 0
 	if *nsFirst {
@@ -75,64 +175,532 @@ func ParseFlags() (*types.Config, error) {
 		return nil, fmt.Errorf("-ns-first, -ns-only, -aec-only, -bypass, and -test-alaw are mutually exclusive")
 	}
 
+	// Set processing chain (replaces the mode)
+	if *chain != "" {
+		if exclusiveCount > 0 {
+			return nil, fmt.Errorf("-chain cannot be combined with -ns-first, -ns-only, -aec-only, -bypass or -test-alaw")
+		}
+		config.Chain = parseChain(*chain)
+	}
+	config.HighPassHz = *hpfCutoff
+	config.GainDB = *gainDB
+
+	// Set sample formats
+	if err := parseFormats(&config, *format, *micFormat, *speakerFormat, *outputFormat); err != nil {
+		return nil, err
+	}
+
 	// Set processing parameters
 	config.SampleRate = *sampleRate
 	config.FrameSize = *frameSize
 	config.EchoTailMs = *echoTailMs
 	config.ProgressSec = *progressSec
 	config.UsePrevSpeaker = *usePrevSpeaker
+	config.SpeakerDelay = *speakerDelay
+	config.SpeakerDelayMs = *speakerDelayMs
 
-	// Derive filter length if not explicitly set
-	config.FilterLen = *filterLenIn
-	if config.FilterLen <= 0 {
-		config.FilterLen = config.SampleRate * config.EchoTailMs / 1000
+	// Set delay alignment parameters
+	config.AutoDelay = *autoDelay
+	config.MaxDelayMs = *maxDelayMs
+	config.DelayWindowSec = *delayWindowSec
+	config.DelayUpdateSec = *delayUpdateSec
+	config.DriftCompensation = *driftComp
+	config.DriftUpdateSec = *driftUpdateSec
+
+	// Set metrics outputs
+	config.MetricsFile = *metricsFile
+	config.MetricsCSV = *metricsCSV
+
+	// Set impulse response output
+	config.ImpulseResponseFile = *impulseFile
+	config.ImpulseResponseSec = *impulseSec
+
+	// Set voice activity outputs
+	config.VADCSV = *vadCSV
+	config.VADJSON = *vadJSON
+	config.VADLabels = *vadLabels
+
+	// Set VAD-gated output
+	switch gate := types.VADGate(*vadGate); gate {
+	case types.GateOff, types.GateSilence, types.GateNoise, types.GateDrop:
+		config.VADGate = gate
+	default:
+		return nil, fmt.Errorf("-vad-gate: unknown mode %q (silence, noise or drop)", *vadGate)
 	}
+	config.ComfortNoiseDB = *comfortNoiseDB
+	config.VADEditList = *vadEditList
+
+	// Set comfort noise
+	config.ComfortFill = *comfortFill
+	config.ComfortNoiseOffsetDB = *comfortNoiseOffset
+
+	// Set speech segmentation
+	config.SegmentDir = *segmentDir
+	config.SegmentPadMs = *segmentPadMs
+	config.SegmentMinMs = *segmentMinMs
+	config.SegmentMaxGapMs = *segmentGapMs
+
+	// Set stream sample rates
+	config.MicRate = *micRate
+	config.SpeakerRate = *speakerRate
+	config.OutputRate = *outputRate
+	config.ResampleQuality = *resampleQuality
+
+	config.FilterLen = *filterLenIn
 
 	// Set noise suppression parameters
+	config.NS.EnableDenoise = *denoise
 	config.NS.NoiseSuppress = *noiseSuppress
 	config.NS.EnableVAD = *enableVAD
 	config.NS.VADProbStart = *vadProbStart
 	config.NS.VADProbCont = *vadProbCont
 	config.NS.EnableAGC = *enableAGC
 	config.NS.AGCLevel = *agcLevel
+	config.NS.AGCIncrement = *agcIncrement
+	config.NS.AGCDecrement = *agcDecrement
+	config.NS.AGCMaxGain = *agcMaxGain
+	config.NS.AGCTarget = *agcTarget
+	if flagSet("agc-target") && *agcTarget <= 0 {
+		return nil, fmt.Errorf("-agc-target must be positive")
+	}
+	config.NS.EnableDereverb = *dereverb
+	config.NS.DereverbLevel = *dereverbLevel
+	config.NS.DereverbDecay = *dereverbDecay
+	config.NS.EchoSuppress = *echoSuppress
+	config.NS.EchoSuppressActive = *echoSuppressActive
 
 	// Validate configuration
-	if err := validateConfig(&config, *help); err != nil {
+	if *help {
+		printHelp(&config)
+		return nil, errMissingParameters
+	}
+	if err := Prepare(&config); err != nil {
+		if errors.Is(err, errMissingParameters) {
+			printHelp(&config)
+		}
 		return nil, err
 	}
 
 	return &config, nil
 }
 
-// validateConfig validates the configuration
-func validateConfig(config *types.Config, help bool) error {
-	// Speaker file is required for all modes except NS-only, bypass, and test-alaw
-	speakerRequired := config.Mode != types.ModeNSOnly && config.Mode != types.ModeBypass && config.Mode != types.ModeTestAlaw
+// Prepare derives the settings implied by others and validates the configuration
+// It is applied to parsed flags and to configurations built in code
+func Prepare(config *types.Config) error {
+	deriveSettings(config)
+
+	interleaved := config.InputFile != ""
+	micMissing := !interleaved && config.MicFile == ""
+	speakerMissing := !interleaved && speakerRequired(config) && config.SpeakerFile == ""
+	if micMissing || speakerMissing {
+		return errMissingParameters
+	}
+	if interleaved && (config.MicFile != "" || config.SpeakerFile != "") {
+		return fmt.Errorf("-input cannot be combined with -mic or -speaker")
+	}
+
+	// Standard input can feed only one stream
+	stdin := 0
+	for _, path := range append([]string{config.InputFile, config.MicFile, config.SpeakerFile}, append(config.ExtraMicFiles, config.ExtraSpeakerFiles...)...) {
+		if path == types.StdioPath {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return fmt.Errorf("only one input can be read from stdin (-)")
+	}
+
+	return validateConfig(config, interleaved)
+}
+
+// PrepareStreams is Prepare for a run on caller-provided streams, which replace
+// the input and output files of the configuration
+func PrepareStreams(config *types.Config, streams types.Streams) error {
+	deriveSettings(config)
+
+	interleaved := streams.Input != nil
+	micMissing := !interleaved && streams.Mic == nil
+	speakerMissing := !interleaved && speakerRequired(config) && streams.Speaker == nil
+	if micMissing || speakerMissing || streams.Output == nil {
+		return fmt.Errorf("missing input or output stream")
+	}
+	if interleaved && (streams.Mic != nil || streams.Speaker != nil) {
+		return fmt.Errorf("an interleaved input stream cannot be combined with mic or speaker streams")
+	}
+	if len(config.ExtraMicFiles) > 0 || len(config.ExtraSpeakerFiles) > 0 ||
+		len(config.ExtraMicChannels) > 0 || len(config.ExtraSpeakerChannels) > 0 {
+		return fmt.Errorf("multi-channel input requires files")
+	}
+
+	return validateConfig(config, interleaved)
+}
+
+// deriveSettings fills in the settings implied by others
+func deriveSettings(config *types.Config) {
+	// Derive filter length if not explicitly set
+	if config.FilterLen <= 0 {
+		config.FilterLen = config.SampleRate * config.EchoTailMs / 1000
+	}
+
+	// VAD outputs need the speech decision, which Speex only reports with VAD enabled
+	if config.NeedsVAD() {
+		config.NS.EnableVAD = true
+	}
+
+	// Stage names are case-insensitive; HasStage compares the lower-case names
+	// (a new slice keeps the caller's chain unchanged)
+	if len(config.Chain) > 0 {
+		chain := make([]string, len(config.Chain))
+		for i, name := range config.Chain {
+			chain[i] = strings.ToLower(strings.TrimSpace(name))
+		}
+		config.Chain = chain
+	}
+}
+
+// speakerRequired reports whether the configuration needs a speaker reference
+func speakerRequired(config *types.Config) bool {
+	// Echo cancellation needs the reference, and stereo output carries it
+	return config.HasStage(types.StageAEC) || config.StereoOutput
+}
+
+// parseChain splits a comma-separated list of stage names
+func parseChain(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		names = append(names, strings.TrimSpace(name))
+	}
+	return names
+}
+
+// validateChain checks the stage names of an explicit processing chain and their parameters
+func validateChain(config *types.Config) error {
+	aecCount := 0
+	for _, name := range config.Chain {
+		if _, err := stage.Lookup(name); err != nil {
+			return fmt.Errorf("-chain: %w", err)
+		}
+		if name == types.StageAEC {
+			aecCount++
+		}
+	}
+	if aecCount > 1 {
+		return fmt.Errorf("-chain: aec can only be used once")
+	}
+	if config.HasStage(types.StageHPF) && (config.HighPassHz <= 0 || config.HighPassHz >= float64(config.SampleRate)/2) {
+		return fmt.Errorf("-hpf-cutoff must be between 0 and half the sample rate")
+	}
+	return nil
+}
+
+// parseFormats resolves per-stream sample formats, falling back to the common format
+func parseFormats(config *types.Config, common, mic, speaker, output string) error {
+	targets := []struct {
+		name   string
+		value  string
+		format *types.SampleFormat
+	}{
+		{"-mic-format", mic, &config.MicFormat},
+		{"-speaker-format", speaker, &config.SpeakerFormat},
+		{"-output-format", output, &config.OutputFormat},
+	}
+
+	for _, t := range targets {
+		value := t.value
+		if value == "" {
+			value = common
+		}
+		codec, err := audio.LookupCodec(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+		*t.format = types.SampleFormat(codec.Name())
+	}
+
+	return nil
+}
+
+// fileList collects the values of a repeatable flag
+type fileList []string
+
+// String returns the values separated by commas
+func (f *fileList) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set appends a value
+func (f *fileList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// flagSet reports whether a flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// listFlag defines a repeatable string flag
+func listFlag(name, usage string) *fileList {
+	list := new(fileList)
+	flag.Var(list, name, usage)
+	return list
+}
+
+// parseChannelMap parses a channel map such as "mic=0,ref=1"
+// Several channels joined with '+' (mic=0+1,ref=2+3) select a multi-channel AEC
+func parseChannelMap(config *types.Config, value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		name, indexes, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("-channel-map: invalid entry %q (want name=index)", entry)
+		}
+		var channels []int
+		for _, index := range strings.Split(indexes, "+") {
+			channel, err := strconv.Atoi(index)
+			if err != nil || channel < 0 {
+				return fmt.Errorf("-channel-map: invalid channel index %q", index)
+			}
+			channels = append(channels, channel)
+		}
+		switch strings.ToLower(name) {
+		case "mic":
+			config.MicChannel = channels[0]
+			config.ExtraMicChannels = channels[1:]
+		case "ref", "speaker":
+			config.SpeakerChannel = channels[0]
+			config.ExtraSpeakerChannels = channels[1:]
+		default:
+			return fmt.Errorf("-channel-map: unknown stream %q (want mic or ref)", name)
+		}
+	}
+	return nil
+}
+
+// validateConfig validates the options of a configuration whose inputs are present
+func validateConfig(config *types.Config, interleaved bool) error {
+	if config.MicRate < 0 || config.SpeakerRate < 0 || config.OutputRate < 0 {
+		return fmt.Errorf("stream sample rates must not be negative")
+	}
+	if interleaved && config.SpeakerRate != 0 && config.SpeakerRate != config.MicRate {
+		return fmt.Errorf("-speaker-rate cannot differ from -mic-rate for an interleaved -input file")
+	}
+	if config.ResampleQuality < 0 || config.ResampleQuality > 10 {
+		return fmt.Errorf("-resample-quality must be between 0 and 10")
+	}
+
+	delayOptions := 0
+	for _, set := range []bool{config.UsePrevSpeaker, config.SpeakerDelay != 0, config.SpeakerDelayMs != 0} {
+		if set {
+			delayOptions++
+		}
+	}
+	if delayOptions > 1 {
+		return fmt.Errorf("-prev-speaker, -speaker-delay, and -speaker-delay-ms are mutually exclusive")
+	}
+
+	if config.AutoDelay {
+		if delayOptions > 0 {
+			return fmt.Errorf("-auto-delay cannot be combined with -prev-speaker, -speaker-delay, or -speaker-delay-ms")
+		}
+		if config.DelayUpdateSec < 0 {
+			return fmt.Errorf("-delay-update must not be negative")
+		}
+	}
+
+	if config.NS.AGCIncrement < 0 {
+		return fmt.Errorf("-agc-increment must not be negative")
+	}
+	if config.NS.AGCDecrement > 0 {
+		return fmt.Errorf("-agc-decrement must not be positive")
+	}
+	if config.NS.AGCMaxGain < 0 || config.NS.AGCTarget < 0 {
+		return fmt.Errorf("-agc-max-gain and -agc-target must not be negative")
+	}
+	if config.NS.DereverbLevel < 0 || config.NS.DereverbLevel > 1 || config.NS.DereverbDecay < 0 || config.NS.DereverbDecay > 1 {
+		return fmt.Errorf("-dereverb-level and -dereverb-decay must be between 0 and 1")
+	}
+
+	if config.NS.EchoSuppress < -100 || config.NS.EchoSuppress > 0 {
+		return fmt.Errorf("-echo-suppress must be between -100 and 0 dB")
+	}
+	if config.NS.EchoSuppressActive < -100 || config.NS.EchoSuppressActive > 0 {
+		return fmt.Errorf("-echo-suppress-active must be between -100 and 0 dB")
+	}
+
+	if err := validateChain(config); err != nil {
+		return err
+	}
+
+	echoMode := config.HasStage(types.StageAEC)
+	// Bypass and the A-law test have no preprocessor and no echo canceller
+	passThrough := !echoMode && !config.HasStage(types.StageNS)
+	if (config.MetricsFile != "" || config.MetricsCSV != "") && !echoMode {
+		return fmt.Errorf("-metrics and -metrics-csv require a mode with echo cancellation")
+	}
+	if config.ImpulseResponseFile != "" && !echoMode {
+		return fmt.Errorf("-impulse-response requires a mode with echo cancellation")
+	}
+	if config.ImpulseResponseSec < 0 {
+		return fmt.Errorf("-impulse-response-interval must not be negative")
+	}
+	if config.ImpulseResponseSec > 0 && config.ImpulseResponseFile == "" {
+		return fmt.Errorf("-impulse-response-interval requires -impulse-response")
+	}
+
+	if config.NeedsVAD() && passThrough {
+		return fmt.Errorf("-vad-csv, -vad-json, -vad-labels, -segment-dir and -vad-gate require an aec or ns stage (not -bypass or -test-alaw)")
+	}
+	if config.VADEditList != "" && config.VADGate != types.GateDrop {
+		return fmt.Errorf("-vad-edit-list requires -vad-gate drop")
+	}
+	if config.ComfortNoiseDB > 0 {
+		return fmt.Errorf("-comfort-noise must not be above 0 dBFS")
+	}
+	if config.ComfortFill && passThrough {
+		return fmt.Errorf("-comfort-fill requires an aec or ns stage (not -bypass or -test-alaw)")
+	}
+	if config.SegmentDir != "" {
+		if config.SegmentPadMs < 0 || config.SegmentMinMs < 0 || config.SegmentMaxGapMs < 0 {
+			return fmt.Errorf("-segment-pad, -segment-min and -segment-max-gap must not be negative")
+		}
+	}
+
+	if config.DriftCompensation && config.DriftUpdateSec <= 0 {
+		return fmt.Errorf("-drift-update must be positive")
+	}
+
+	if config.AutoDelay || config.DriftCompensation {
+		if config.MaxDelayMs <= 0 {
+			return fmt.Errorf("-max-delay must be positive")
+		}
+		if config.DelayWindowSec*1000 <= float64(config.MaxDelayMs) {
+			return fmt.Errorf("-delay-window must be longer than -max-delay")
+		}
+	}
 
-	if help || config.MicFile == "" || (speakerRequired && config.SpeakerFile == "") {
-		printHelp(config)
-		if help || config.MicFile == "" || (speakerRequired && config.SpeakerFile == "") {
-			return fmt.Errorf("missing required parameters")
+	if interleaved {
+		if config.InputChannels < 1 {
+			return fmt.Errorf("-input-channels must be at least 1")
+		}
+		channels := append([]int{config.MicChannel}, config.ExtraMicChannels...)
+		if speakerRequired(config) {
+			channels = append(channels, config.SpeakerChannel)
+			channels = append(channels, config.ExtraSpeakerChannels...)
 		}
+		seen := make(map[int]bool)
+		for _, ch := range channels {
+			if seen[ch] {
+				return fmt.Errorf("-channel-map: mic and ref must use different channels")
+			}
+			seen[ch] = true
+		}
+	}
+
+	if config.MultiChannel() {
+		return validateMultiChannel(config)
+	}
+	return nil
+}
+
+// validateMultiChannel rejects options that only support one mic and one speaker channel
+func validateMultiChannel(config *types.Config) error {
+	if !slices.Equal(config.Stages(), types.ModeAECFirst.Chain()) && !slices.Equal(config.Stages(), types.ModeAECOnly.Chain()) {
+		return fmt.Errorf("multi-channel input requires the default mode, -aec-only or -chain aec[,ns]")
 	}
 
+	unsupported := []struct {
+		set  bool
+		name string
+	}{
+		{config.StereoOutput, "-stereo-output"},
+		{config.AutoDelay, "-auto-delay"},
+		{config.DriftCompensation, "-drift-comp"},
+		{config.SpeakerDelaySamples() != 0, "-speaker-delay, -speaker-delay-ms and -prev-speaker"},
+		{config.MetricsFile != "" || config.MetricsCSV != "", "-metrics and -metrics-csv"},
+		{config.ImpulseResponseFile != "", "-impulse-response"},
+		{config.NeedsVAD(), "VAD outputs and -vad-gate"},
+		{config.ComfortFill, "-comfort-fill"},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("%s cannot be used with multi-channel input", u.name)
+		}
+	}
 	return nil
 }
 
 // printHelp prints the help message
 func printHelp(config *types.Config) {
 	fmt.Fprintf(os.Stderr, "Open Tool Speex\n\n")
-	fmt.Fprintf(os.Stderr, "Usage: %s -mic <mic_file> [-speaker <speaker_file>] [-output <output_file>]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s -mic <mic_file> [-speaker <speaker_file>] [-output <output_file>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s -input <interleaved_file> [-channel-map mic=0,ref=1] [-output <output_file>]\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "Parameters:\n")
-	fmt.Fprintf(os.Stderr, "  -mic              Microphone input file (raw A-law, 16kHz mono)\n")
-	fmt.Fprintf(os.Stderr, "  -speaker          Speaker reference file (raw A-law, 16kHz mono, required for AEC) \n")
-	fmt.Fprintf(os.Stderr, "  -output           Output file (default: %s)\n", config.OutputFile)
-	fmt.Fprintf(os.Stderr, "  -prev-speaker     Use previous speaker frame for delay compensation\n")
+	fmt.Fprintf(os.Stderr, "  -mic              Microphone input file (raw or WAV, mono; - reads stdin)\n")
+	fmt.Fprintf(os.Stderr, "  -speaker          Speaker reference file (raw or WAV, mono, required for AEC; - reads stdin)\n")
+	fmt.Fprintf(os.Stderr, "  -output           Output file, WAV if the name ends in .wav, - writes raw samples to stdout (default: %s)\n", config.OutputFile)
+	fmt.Fprintf(os.Stderr, "  -prev-speaker     Use previous speaker frame for delay compensation (one-frame -speaker-delay)\n")
 	fmt.Fprintf(os.Stderr, "  -ns-first         Apply Noise Suppression before Echo Cancellation\n")
 	fmt.Fprintf(os.Stderr, "  -ns-only          Apply only Noise Suppression (no echo cancellation)\n")
 	fmt.Fprintf(os.Stderr, "  -aec-only         Apply only Echo Cancellation (no noise suppression)\n")
 	fmt.Fprintf(os.Stderr, "  -bypass           Bypass all processing (copy input to output for testing)\n")
 	fmt.Fprintf(os.Stderr, "  -test-alaw        Test A-law encoding/decoding (A-law -> PCM -> A-law)\n\n")
+	fmt.Fprintf(os.Stderr, "Processing Chain (replaces the mode flags above):\n")
+	fmt.Fprintf(os.Stderr, "  -chain            Stages run in order, e.g. hpf,aec,ns,agc (%s)\n", strings.Join(stage.Names(), ", "))
+	fmt.Fprintf(os.Stderr, "                    Modes: default = aec,ns; -aec-only = aec; -ns-only = ns; -ns-first = ns,aec\n")
+	fmt.Fprintf(os.Stderr, "  -hpf-cutoff       Cutoff of the hpf stage in Hz (default: %.0f)\n", config.HighPassHz)
+	fmt.Fprintf(os.Stderr, "  -gain             Gain of the gain stage in dB (default: %.1f)\n\n", config.GainDB)
+	fmt.Fprintf(os.Stderr, "Delay Alignment:\n")
+	fmt.Fprintf(os.Stderr, "  -speaker-delay    Delay the speaker by N samples (negative advances it by delaying the mic)\n")
+	fmt.Fprintf(os.Stderr, "  -speaker-delay-ms Delay the speaker by N milliseconds (negative advances it)\n")
+	fmt.Fprintf(os.Stderr, "  -auto-delay       Estimate the speaker-to-mic delay and shift the speaker stream (replaces -prev-speaker)\n")
+	fmt.Fprintf(os.Stderr, "  -max-delay        Largest delay searched in ms (default: %d)\n", config.MaxDelayMs)
+	fmt.Fprintf(os.Stderr, "  -delay-window     Analysis window in seconds (default: %.1f)\n", config.DelayWindowSec)
+	fmt.Fprintf(os.Stderr, "  -delay-update     Re-estimate every N seconds (default: %.1f; 0 = once at start)\n", config.DelayUpdateSec)
+	fmt.Fprintf(os.Stderr, "  -drift-comp       Measure clock drift and resample the speaker stream to cancel it\n")
+	fmt.Fprintf(os.Stderr, "  -drift-update     Drift measurement interval in seconds (default: %.1f)\n\n", config.DriftUpdateSec)
+	fmt.Fprintf(os.Stderr, "Echo Metrics (modes with echo cancellation):\n")
+	fmt.Fprintf(os.Stderr, "  -metrics          JSON summary: ERLE, residual echo level, far-end/near-end activity\n")
+	fmt.Fprintf(os.Stderr, "  -metrics-csv      Per-frame levels, activity and ERLE as CSV\n")
+	fmt.Fprintf(os.Stderr, "  -impulse-response Echo path learned by the adaptive filter as CSV (WAV if the name ends in .wav)\n")
+	fmt.Fprintf(os.Stderr, "  -impulse-response-interval  Also write numbered snapshots every N seconds (0 = at the end only)\n\n")
+	fmt.Fprintf(os.Stderr, "Voice Activity (enables -vad; chains without ns run VAD on their output):\n")
+	fmt.Fprintf(os.Stderr, "  -vad-csv          Per-frame VAD decision and speech probability as CSV\n")
+	fmt.Fprintf(os.Stderr, "  -vad-json         Per-frame timeline and speech segments as JSON\n")
+	fmt.Fprintf(os.Stderr, "  -vad-labels       Speech segments as an Audacity label track\n")
+	fmt.Fprintf(os.Stderr, "  -segment-dir      Also write each speech segment to its own file with a segments.json manifest\n")
+	fmt.Fprintf(os.Stderr, "  -segment-pad      Padding around each segment in ms (default: %d)\n", config.SegmentPadMs)
+	fmt.Fprintf(os.Stderr, "  -segment-min      Shortest segment kept in ms (default: %d)\n", config.SegmentMinMs)
+	fmt.Fprintf(os.Stderr, "  -segment-max-gap  Longest silence inside a segment in ms (default: %d)\n", config.SegmentMaxGapMs)
+	fmt.Fprintf(os.Stderr, "  -vad-gate         Replace frames without speech: silence, noise or drop\n")
+	fmt.Fprintf(os.Stderr, "  -comfort-noise    Comfort noise level in dBFS (default: %.1f)\n", config.ComfortNoiseDB)
+	fmt.Fprintf(os.Stderr, "  -vad-edit-list    CSV with the source time of the ranges kept by -vad-gate drop\n\n")
+	fmt.Fprintf(os.Stderr, "Comfort Noise (shaped like the mic background, also used by -vad-gate noise):\n")
+	fmt.Fprintf(os.Stderr, "  -comfort-fill     Fill suppressed output frames up to the background level\n")
+	fmt.Fprintf(os.Stderr, "  -comfort-noise-offset Level relative to the background after -noise-suppress in dB (default: %.1f)\n\n", config.ComfortNoiseOffsetDB)
+	fmt.Fprintf(os.Stderr, "Interleaved Input:\n")
+	fmt.Fprintf(os.Stderr, "  -input            Interleaved input file with mic and speaker channels (replaces -mic/-speaker; - reads stdin)\n")
+	fmt.Fprintf(os.Stderr, "  -input-channels   Channel count of a raw -input file (default: %d; WAV uses its header)\n", config.InputChannels)
+	fmt.Fprintf(os.Stderr, "  -channel-map      Channels of mic and reference (default: mic=%d,ref=%d)\n", config.MicChannel, config.SpeakerChannel)
+	fmt.Fprintf(os.Stderr, "  -stereo-output    Write processed mic (left) and original reference (right) for A/B listening\n\n")
+	fmt.Fprintf(os.Stderr, "Multi-Channel AEC (N mics, M speaker references -> N cleaned channels):\n")
+	fmt.Fprintf(os.Stderr, "  -mic, -speaker    Repeat for each mono channel file (-mic m0.wav -mic m1.wav -speaker l.wav -speaker r.wav)\n")
+	fmt.Fprintf(os.Stderr, "  -channel-map      Join channels of an -input file with '+' (mic=0+1,ref=2+3)\n\n")
+	fmt.Fprintf(os.Stderr, "Sample Formats (%s; WAV inputs use their header):\n", strings.Join(audio.CodecNames(), ", "))
+	fmt.Fprintf(os.Stderr, "  -format           Format of all raw streams (default: %s)\n", config.OutputFormat)
+	fmt.Fprintf(os.Stderr, "  -mic-format       Format of the mic file (overrides -format)\n")
+	fmt.Fprintf(os.Stderr, "  -speaker-format   Format of the speaker file (overrides -format)\n")
+	fmt.Fprintf(os.Stderr, "  -output-format    Format of the output file (overrides -format)\n\n")
+	fmt.Fprintf(os.Stderr, "Sample Rates (0 = -sample-rate; streams are resampled to the processing rate):\n")
+	fmt.Fprintf(os.Stderr, "  -mic-rate         Rate of a raw mic file or -input file in Hz (WAV uses its header)\n")
+	fmt.Fprintf(os.Stderr, "  -speaker-rate     Rate of a raw speaker file in Hz (WAV uses its header)\n")
+	fmt.Fprintf(os.Stderr, "  -output-rate      Rate of the output file in Hz\n")
+	fmt.Fprintf(os.Stderr, "  -resample-quality Resampler quality 0-10 (default: %d)\n\n", config.ResampleQuality)
 	fmt.Fprintf(os.Stderr, "Processing Parameters:\n")
 	fmt.Fprintf(os.Stderr, "  -sample-rate      Sample rate in Hz (default: %d)\n", config.SampleRate)
 	fmt.Fprintf(os.Stderr, "  -frame-size       Frame size in samples (default: %d)\n", config.FrameSize)
@@ -140,12 +708,23 @@ func printHelp(config *types.Config) {
 	fmt.Fprintf(os.Stderr, "  -filter-len       Echo filter length in samples (override echo-tail if > 0)\n")
 	fmt.Fprintf(os.Stderr, "  -progress-sec     Progress log interval in seconds (default: %.1f; 0 disables)\n\n", config.ProgressSec)
 	fmt.Fprintf(os.Stderr, "Noise Suppression Settings:\n")
+	fmt.Fprintf(os.Stderr, "  -denoise          Enable noise suppression (default: %v; -denoise=false disables it)\n", config.NS.EnableDenoise)
 	fmt.Fprintf(os.Stderr, "  -noise-suppress   Noise suppression level in dB (default: %.1f, more negative = more suppression)\n", config.NS.NoiseSuppress)
 	fmt.Fprintf(os.Stderr, "  -vad              Enable Voice Activity Detection\n")
 	fmt.Fprintf(os.Stderr, "  -vad-prob-start   VAD probability threshold for speech start 0-100 (default: %d)\n", config.NS.VADProbStart)
 	fmt.Fprintf(os.Stderr, "  -vad-prob-continue VAD probability threshold for speech continue 0-100 (default: %d)\n", config.NS.VADProbCont)
 	fmt.Fprintf(os.Stderr, "  -agc              Enable Automatic Gain Control\n")
-	fmt.Fprintf(os.Stderr, "  -agc-level        AGC target RMS level (default: %.1f)\n\n", config.NS.AGCLevel)
+	fmt.Fprintf(os.Stderr, "  -agc-level        AGC target RMS level (default: %.1f)\n", config.NS.AGCLevel)
+	fmt.Fprintf(os.Stderr, "  -agc-increment    Maximal AGC gain increase in dB/s (default: %d)\n", config.NS.AGCIncrement)
+	fmt.Fprintf(os.Stderr, "  -agc-decrement    Maximal AGC gain decrease in dB/s, negative (default: %d)\n", config.NS.AGCDecrement)
+	fmt.Fprintf(os.Stderr, "  -agc-max-gain     Maximal AGC gain in dB, 0 = no gain (default: %d)\n", config.NS.AGCMaxGain)
+	fmt.Fprintf(os.Stderr, "  -agc-target       Integer AGC target level (positive), overrides -agc-level when given\n")
+	fmt.Fprintf(os.Stderr, "  -dereverb         Enable dereverberation\n")
+	fmt.Fprintf(os.Stderr, "  -dereverb-level   Dereverberation level 0-1 (default: %.1f)\n", config.NS.DereverbLevel)
+	fmt.Fprintf(os.Stderr, "  -dereverb-decay   Dereverberation decay 0-1 (default: %.1f)\n\n", config.NS.DereverbDecay)
+	fmt.Fprintf(os.Stderr, "Residual Echo Suppression (AEC -> NS mode):\n")
+	fmt.Fprintf(os.Stderr, "  -echo-suppress    Residual echo attenuation in dB, -100 to 0, 0 = none (default: %d)\n", config.NS.EchoSuppress)
+	fmt.Fprintf(os.Stderr, "  -echo-suppress-active Attenuation while the near end talks, -100 to 0, 0 = none (default: %d)\n\n", config.NS.EchoSuppressActive)
 	fmt.Fprintf(os.Stderr, "  -help             Show this help\n\n")
 	fmt.Fprintf(os.Stderr, "Frame size: %d samples (%.1fms)\n", config.FrameSize, float64(config.FrameSize)/float64(config.SampleRate)*1000)
 	fmt.Fprintf(os.Stderr, "Echo tail: %dms (%d samples)\n", config.EchoTailMs, config.FilterLen)
@@ -184,6 +184,891 @@ func TestParseFlags(t *testing.T) {
 				return cfg.UsePrevSpeaker == true
 			},
 		},
+		{
+			name: "interleaved input with channel map",
+			args: []string{
+				"open_tool_speex",
+				"-input", "stereo.wav",
+				"-channel-map", "mic=1,ref=0",
+				"-stereo-output",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.InputFile == "stereo.wav" &&
+					cfg.MicChannel == 1 &&
+					cfg.SpeakerChannel == 0 &&
+					cfg.StereoOutput == true
+			},
+		},
+		{
+			name: "interleaved input default channel map",
+			args: []string{
+				"open_tool_speex",
+				"-input", "stereo.raw",
+				"-input-channels", "4",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.InputChannels == 4 &&
+					cfg.MicChannel == 0 &&
+					cfg.SpeakerChannel == 1
+			},
+		},
+		{
+			name: "stdin and stdout",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "-",
+				"-speaker", "ref.alaw",
+				"-output", "-",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicFile == types.StdioPath && cfg.OutputFile == types.StdioPath
+			},
+		},
+		{
+			name: "stdin for two inputs",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "-",
+				"-speaker", "-",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "interleaved input combined with mic",
+			args: []string{
+				"open_tool_speex",
+				"-input", "stereo.wav",
+				"-mic", "test.alaw",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "channel map with shared channel",
+			args: []string{
+				"open_tool_speex",
+				"-input", "stereo.wav",
+				"-channel-map", "mic=0,ref=0",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "invalid channel map",
+			args: []string{
+				"open_tool_speex",
+				"-input", "stereo.wav",
+				"-channel-map", "left=0",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "stereo output requires speaker",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-stereo-output",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "common sample format",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.ulaw",
+				"-speaker", "ref.ulaw",
+				"-format", "mulaw",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicFormat == types.FormatMuLaw &&
+					cfg.SpeakerFormat == types.FormatMuLaw &&
+					cfg.OutputFormat == types.FormatMuLaw
+			},
+		},
+		{
+			name: "per-stream sample formats",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.ulaw",
+				"-speaker", "ref.alaw",
+				"-output", "out.pcm",
+				"-mic-format", "mulaw",
+				"-output-format", "pcm16",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicFormat == types.FormatMuLaw &&
+					cfg.SpeakerFormat == types.FormatALaw &&
+					cfg.OutputFormat == types.FormatPCM16
+			},
+		},
+		{
+			name: "linear sample formats",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.raw",
+				"-speaker", "ref.raw",
+				"-mic-format", "s16le",
+				"-speaker-format", "s16be",
+				"-output-format", "f32le",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicFormat == types.FormatPCM16 &&
+					cfg.SpeakerFormat == types.FormatS16BE &&
+					cfg.OutputFormat == types.FormatF32LE
+			},
+		},
+		{
+			name: "unknown sample format",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-output-format", "mp3",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "speaker delay in samples",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-speaker-delay", "-480",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.SpeakerDelay == -480 && cfg.SpeakerDelaySamples() == -480
+			},
+		},
+		{
+			name: "speaker delay in milliseconds",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-speaker-delay-ms", "45",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.SpeakerDelayMs == 45 && cfg.SpeakerDelaySamples() == 720
+			},
+		},
+		{
+			name: "speaker delay with prev-speaker",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-prev-speaker",
+				"-speaker-delay", "100",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "auto delay",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-auto-delay",
+				"-max-delay", "300",
+				"-delay-update", "5",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.AutoDelay &&
+					cfg.MaxDelayMs == 300 &&
+					cfg.DelayWindowSec == 2.0 &&
+					cfg.DelayUpdateSec == 5
+			},
+		},
+		{
+			name: "auto delay with prev-speaker",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-auto-delay",
+				"-prev-speaker",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "auto delay window shorter than max delay",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-auto-delay",
+				"-max-delay", "500",
+				"-delay-window", "0.4",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "drift compensation",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-drift-comp",
+				"-drift-update", "4",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.DriftCompensation && cfg.DriftUpdateSec == 4 && !cfg.AutoDelay
+			},
+		},
+		{
+			name: "drift compensation with zero interval",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-drift-comp",
+				"-drift-update", "0",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "echo metrics",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-metrics", "metrics.json",
+				"-metrics-csv", "frames.csv",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MetricsFile == "metrics.json" && cfg.MetricsCSV == "frames.csv"
+			},
+		},
+		{
+			name: "echo metrics without echo cancellation",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-metrics", "metrics.json",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "impulse response",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-impulse-response", "ir.wav",
+				"-impulse-response-interval", "5",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.ImpulseResponseFile == "ir.wav" && cfg.ImpulseResponseSec == 5
+			},
+		},
+		{
+			name: "impulse response without echo cancellation",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-impulse-response", "ir.csv",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "impulse response interval without file",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-impulse-response-interval", "5",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "vad timeline enables vad",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-vad-csv", "vad.csv",
+				"-vad-json", "vad.json",
+				"-vad-labels", "speech.txt",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.EnableVAD && cfg.VADCSV == "vad.csv" && cfg.VADJSON == "vad.json" && cfg.VADLabels == "speech.txt"
+			},
+		},
+		{
+			name: "vad timeline in bypass mode",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-bypass",
+				"-vad-labels", "speech.txt",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "speech segmentation",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-segment-dir", "segments",
+				"-segment-pad", "100",
+				"-segment-min", "500",
+				"-segment-max-gap", "250",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.EnableVAD && cfg.SegmentDir == "segments" &&
+					cfg.SegmentPadMs == 100 && cfg.SegmentMinMs == 500 && cfg.SegmentMaxGapMs == 250
+			},
+		},
+		{
+			name: "speech segmentation with negative padding",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-segment-dir", "segments",
+				"-segment-pad", "-10",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "vad gate drop with edit list",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-aec-only",
+				"-vad-gate", "drop",
+				"-vad-edit-list", "edits.csv",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.EnableVAD && cfg.VADGate == types.GateDrop && cfg.VADEditList == "edits.csv"
+			},
+		},
+		{
+			name: "vad gate comfort noise",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-vad-gate", "noise",
+				"-comfort-noise", "-70",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.VADGate == types.GateNoise && cfg.ComfortNoiseDB == -70 && !cfg.ComfortFill
+			},
+		},
+		{
+			name: "comfort noise above full scale",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-vad-gate", "noise",
+				"-comfort-noise", "3",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "comfort fill",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-comfort-fill",
+				"-comfort-noise-offset", "-3",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.ComfortFill && cfg.ComfortNoiseOffsetDB == -3 && cfg.ComfortNoiseDB == -60
+			},
+		},
+		{
+			name: "comfort fill in bypass mode",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-bypass",
+				"-comfort-fill",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "unknown vad gate",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-vad-gate", "mute",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "edit list without drop",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-ns-only",
+				"-vad-gate", "silence",
+				"-vad-edit-list", "edits.csv",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "multi-channel files",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "mic0.wav",
+				"-mic", "mic1.wav",
+				"-speaker", "left.wav",
+				"-speaker", "right.wav",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicFile == "mic0.wav" && len(cfg.ExtraMicFiles) == 1 && cfg.ExtraMicFiles[0] == "mic1.wav" &&
+					cfg.SpeakerFile == "left.wav" && len(cfg.ExtraSpeakerFiles) == 1 &&
+					cfg.MicChannels() == 2 && cfg.SpeakerChannels() == 2 && cfg.MultiChannel()
+			},
+		},
+		{
+			name: "multi-channel interleaved input",
+			args: []string{
+				"open_tool_speex",
+				"-input", "array.wav",
+				"-channel-map", "mic=0+1,ref=2+3",
+				"-aec-only",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicChannel == 0 && len(cfg.ExtraMicChannels) == 1 && cfg.ExtraMicChannels[0] == 1 &&
+					cfg.SpeakerChannel == 2 && len(cfg.ExtraSpeakerChannels) == 1 && cfg.ExtraSpeakerChannels[0] == 3 &&
+					cfg.MicChannels() == 2 && cfg.SpeakerChannels() == 2
+			},
+		},
+		{
+			name: "multi-channel interleaved input with a shared channel",
+			args: []string{
+				"open_tool_speex",
+				"-input", "array.wav",
+				"-channel-map", "mic=0+1,ref=1+2",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "multi-channel with ns-first",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "mic0.wav",
+				"-mic", "mic1.wav",
+				"-speaker", "ref.wav",
+				"-ns-first",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "processing chain",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-chain", "hpf, AEC,ns,agc",
+				"-hpf-cutoff", "80",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return len(cfg.Chain) == 4 && cfg.Chain[1] == types.StageAEC && cfg.HighPassHz == 80 &&
+					cfg.HasStage(types.StageAGC)
+			},
+		},
+		{
+			name: "processing chain without aec needs no speaker",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "hpf,ns,gain",
+				"-gain", "6",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.SpeakerFile == "" && cfg.GainDB == 6
+			},
+		},
+		{
+			name: "processing chain with aec needs speaker",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "aec,ns",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "processing chain with unknown stage",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "hpf,reverb",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "processing chain with two aec stages",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-chain", "aec,ns,aec",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "processing chain with mode flag",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "ns",
+				"-ns-only",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "hpf cutoff above nyquist",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "hpf",
+				"-hpf-cutoff", "9000",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "vad timeline with a chain without ns",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-chain", "hpf,gain",
+				"-vad-csv", "vad.csv",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "multi-channel with aec chain",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "mic0.wav",
+				"-mic", "mic1.wav",
+				"-speaker", "ref.wav",
+				"-chain", "aec",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MultiChannel()
+			},
+		},
+		{
+			name: "multi-channel with hpf chain",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "mic0.wav",
+				"-mic", "mic1.wav",
+				"-speaker", "ref.wav",
+				"-chain", "hpf,aec",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "multi-channel with auto delay",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "mic0.wav",
+				"-speaker", "left.wav",
+				"-speaker", "right.wav",
+				"-auto-delay",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "residual echo suppression",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-echo-suppress", "-60",
+				"-echo-suppress-active", "-25",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.EchoSuppress == -60 && cfg.NS.EchoSuppressActive == -25
+			},
+		},
+		{
+			name: "echo suppression disabled",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-echo-suppress", "0",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.EchoSuppress == 0 && cfg.NS.EchoSuppressActive == -15
+			},
+		},
+		{
+			name: "positive echo suppression",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-echo-suppress", "10",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "echo suppression while active out of range",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-echo-suppress-active", "-150",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "dereverb and bounded agc",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "room.alaw",
+				"-ns-only",
+				"-denoise=false",
+				"-dereverb",
+				"-dereverb-level", "0.4",
+				"-dereverb-decay", "0.5",
+				"-agc",
+				"-agc-increment", "6",
+				"-agc-decrement", "-30",
+				"-agc-max-gain", "12",
+				"-agc-target", "16000",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return !cfg.NS.EnableDenoise &&
+					cfg.NS.EnableDereverb &&
+					cfg.NS.DereverbLevel == 0.4 &&
+					cfg.NS.DereverbDecay == 0.5 &&
+					cfg.NS.AGCIncrement == 6 &&
+					cfg.NS.AGCDecrement == -30 &&
+					cfg.NS.AGCMaxGain == 12 &&
+					cfg.NS.AGCTarget == 16000
+			},
+		},
+		{
+			name: "agc without gain",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "room.alaw",
+				"-ns-only",
+				"-agc",
+				"-agc-max-gain", "0",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.NS.AGCMaxGain == 0 && cfg.NS.AGCIncrement == 12 && cfg.NS.AGCDecrement == -40
+			},
+		},
+		{
+			name: "zero agc target",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "room.alaw",
+				"-ns-only",
+				"-agc-target", "0",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "positive agc decrement",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "room.alaw",
+				"-ns-only",
+				"-agc-decrement", "10",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "dereverb level out of range",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "room.alaw",
+				"-ns-only",
+				"-dereverb-level", "2",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "per-stream sample rates",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.raw",
+				"-speaker-rate", "48000",
+				"-output-rate", "8000",
+				"-resample-quality", "8",
+			},
+			wantErr: false,
+			check: func(cfg *types.Config) bool {
+				return cfg.MicRate == 0 &&
+					cfg.SpeakerRate == 48000 &&
+					cfg.OutputRate == 8000 &&
+					cfg.ResampleQuality == 8
+			},
+		},
+		{
+			name: "resample quality out of range",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-resample-quality", "11",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
+		{
+			name: "negative stream rate",
+			args: []string{
+				"open_tool_speex",
+				"-mic", "test.alaw",
+				"-speaker", "ref.alaw",
+				"-output-rate", "-8000",
+			},
+			wantErr: true,
+			check: func(cfg *types.Config) bool {
+				return true // Error expected
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -216,6 +1101,10 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig() OutputFile = %s, want output.alaw", cfg.OutputFile)
 	}
 
+	if cfg.MicFormat != types.FormatALaw || cfg.SpeakerFormat != types.FormatALaw || cfg.OutputFormat != types.FormatALaw {
+		t.Errorf("DefaultConfig() formats = %v/%v/%v, want alaw", cfg.MicFormat, cfg.SpeakerFormat, cfg.OutputFormat)
+	}
+
 	if cfg.Mode != types.ModeAECFirst {
 		t.Errorf("DefaultConfig() Mode = %v, want %v", cfg.Mode, types.ModeAECFirst)
 	}
@@ -236,6 +1125,10 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig() NS.NoiseSuppress = %f, want -15.0", cfg.NS.NoiseSuppress)
 	}
 
+	if cfg.NS.EnableDenoise != true {
+		t.Errorf("DefaultConfig() NS.EnableDenoise = %v, want true", cfg.NS.EnableDenoise)
+	}
+
 	if cfg.NS.EnableVAD != false {
 		t.Errorf("DefaultConfig() NS.EnableVAD = %v, want false", cfg.NS.EnableVAD)
 	}
@@ -255,4 +1148,13 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.NS.AGCLevel != 30000.0 {
 		t.Errorf("DefaultConfig() NS.AGCLevel = %f, want 30000.0", cfg.NS.AGCLevel)
 	}
+
+	if cfg.NS.EchoSuppress != -40 || cfg.NS.EchoSuppressActive != -15 {
+		t.Errorf("DefaultConfig() NS.EchoSuppress/Active = %d/%d, want -40/-15", cfg.NS.EchoSuppress, cfg.NS.EchoSuppressActive)
+	}
+
+	if cfg.NS.AGCIncrement != 12 || cfg.NS.AGCDecrement != -40 || cfg.NS.AGCMaxGain != 30 || cfg.NS.AGCTarget != 0 {
+		t.Errorf("DefaultConfig() NS AGC dynamics = %d/%d/%d/%d, want 12/-40/30/0",
+			cfg.NS.AGCIncrement, cfg.NS.AGCDecrement, cfg.NS.AGCMaxGain, cfg.NS.AGCTarget)
+	}
 }
@@ -0,0 +1,41 @@
+package dsp
+
+import "math"
+
+// Biquad is a second-order IIR filter in transposed direct form II
+type Biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+// NewHighPass creates a Butterworth high-pass filter with the given cutoff frequency
+func NewHighPass(cutoffHz float64, sampleRate int) *Biquad {
+	w := 2 * math.Pi * cutoffHz / float64(sampleRate)
+	alpha := math.Sin(w) / math.Sqrt2 // Q = 1/sqrt(2)
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return &Biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Process filters src into dst (which may be src)
+func (bq *Biquad) Process(dst, src []int16) {
+	for i, s := range src {
+		x := float64(s)
+		y := bq.b0*x + bq.z1
+		bq.z1 = bq.b1*x - bq.a1*y + bq.z2
+		bq.z2 = bq.b2*x - bq.a2*y
+		dst[i] = clampInt16(y)
+	}
+}
+
+// Reset clears the filter state
+func (bq *Biquad) Reset() {
+	bq.z1, bq.z2 = 0, 0
+}
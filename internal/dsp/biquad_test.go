@@ -0,0 +1,62 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHighPass(t *testing.T) {
+	const rate = 16000
+	tests := []struct {
+		name     string
+		freq     float64 // 0 = DC
+		wantGain float64 // output to input RMS ratio
+	}{
+		{name: "dc", freq: 0, wantGain: 0},
+		{name: "below cutoff", freq: 20, wantGain: 0.04},
+		{name: "at cutoff", freq: 100, wantGain: 1 / math.Sqrt2},
+		{name: "passband", freq: 1000, wantGain: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpf := NewHighPass(100, rate)
+			src := make([]int16, rate)
+			for i := range src {
+				src[i] = int16(8000 * math.Cos(2*math.Pi*tt.freq*float64(i)/rate))
+			}
+			dst := make([]int16, len(src))
+			hpf.Process(dst, src)
+
+			// Skip the transient of the first half second
+			gain := rms(dst[rate/2:]) / rms(src[rate/2:])
+			if math.Abs(gain-tt.wantGain) > 0.02 {
+				t.Errorf("gain = %.3f, want %.3f", gain, tt.wantGain)
+			}
+		})
+	}
+}
+
+func TestHighPass_Reset(t *testing.T) {
+	hpf := NewHighPass(100, 16000)
+	frame := []int16{1000, 2000, -3000, 4000}
+	first := make([]int16, len(frame))
+	hpf.Process(first, frame)
+
+	hpf.Reset()
+	second := make([]int16, len(frame))
+	hpf.Process(second, frame)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("output after Reset = %v, want %v", second, first)
+		}
+	}
+}
+
+// rms returns the root mean square of a signal
+func rms(s []int16) float64 {
+	sum := 0.0
+	for _, v := range s {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(s)))
+}
@@ -0,0 +1,182 @@
+package dsp
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Background tracking: the frame energy floor falls fast and rises slowly so speech and echo
+// bursts are ignored; the spectrum is averaged over frames close to the floor
+const (
+	floorFallRate  = 0.3
+	floorRiseRate  = 0.005
+	quietFrameRate = 0.1
+	quietThreshold = 2.0 // frames up to 3 dB above the floor count as background
+)
+
+// ComfortNoise estimates the background noise spectrum of a signal and synthesizes
+// noise with the same spectral shape and level
+type ComfortNoise struct {
+	frameSize int
+	gain      float64 // level offset as a power ratio
+	window    []float64
+	windowSum float64 // sum of squared window samples
+	psd       []float64
+	floor     float64 // energy floor of the windowed frames
+	spectrum  []complex128
+	tail      []float64 // end of the previous block, crossfaded into the next
+	noise     []int16
+	rng       *rand.Rand
+	updated   bool
+}
+
+// NewComfortNoise creates a generator for frames of frameSize samples
+// offsetDB shifts the generated level relative to the estimated background
+func NewComfortNoise(frameSize int, offsetDB float64, seed int64) *ComfortNoise {
+	n := NextPowerOfTwo(frameSize + frameSize/4)
+	window := make([]float64, frameSize)
+	windowSum := 0.0
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*(float64(i)+0.5)/float64(frameSize))
+		windowSum += window[i] * window[i]
+	}
+	return &ComfortNoise{
+		frameSize: frameSize,
+		gain:      math.Pow(10, offsetDB/10),
+		window:    window,
+		windowSum: windowSum,
+		psd:       make([]float64, n),
+		spectrum:  make([]complex128, n),
+		tail:      make([]float64, frameSize/4),
+		noise:     make([]int16, frameSize),
+		rng:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Update feeds a frame of the noisy signal into the background estimate
+func (cn *ComfortNoise) Update(frame []int16) {
+	for i := range cn.spectrum {
+		if i < len(frame) && i < len(cn.window) {
+			cn.spectrum[i] = complex(float64(frame[i])*cn.window[i], 0)
+		} else {
+			cn.spectrum[i] = 0
+		}
+	}
+	FFT(cn.spectrum)
+
+	energy := 0.0
+	for _, x := range cn.spectrum {
+		energy += real(x)*real(x) + imag(x)*imag(x)
+	}
+	switch {
+	case !cn.updated:
+		cn.floor = energy
+	case energy < cn.floor:
+		cn.floor += floorFallRate * (energy - cn.floor)
+	default:
+		cn.floor += floorRiseRate * (energy - cn.floor)
+	}
+	if cn.updated && energy > quietThreshold*cn.floor {
+		return
+	}
+
+	for i, x := range cn.spectrum {
+		power := real(x)*real(x) + imag(x)*imag(x)
+		if cn.updated {
+			cn.psd[i] += quietFrameRate * (power - cn.psd[i])
+		} else {
+			cn.psd[i] = power
+		}
+	}
+	cn.updated = true
+}
+
+// Power returns the mean square of the generated noise per sample
+func (cn *ComfortNoise) Power() float64 {
+	sum := 0.0
+	for _, p := range cn.psd {
+		sum += p
+	}
+	return cn.gain * sum / (float64(len(cn.psd)) * cn.windowSum)
+}
+
+// Generate fills dst (one frame) with noise matching the estimated background
+func (cn *ComfortNoise) Generate(dst []int16) {
+	cn.generate(dst, cn.gain, false)
+}
+
+// GenerateLevel fills dst (one frame) with noise shaped like the estimated background
+// at a fixed level in dBFS; the noise is white until a background has been seen
+func (cn *ComfortNoise) GenerateLevel(dst []int16, dBFS float64) {
+	sum := 0.0
+	for _, p := range cn.psd {
+		sum += p
+	}
+	flat := sum <= 0
+	if flat {
+		sum = float64(len(cn.psd))
+	}
+	// Inverse of Power: the gain that turns the spectrum sum into the target mean square
+	power := 32768 * 32768 * math.Pow(10, dBFS/10)
+	cn.generate(dst, power*float64(len(cn.psd))*cn.windowSum/sum, flat)
+}
+
+// generate synthesizes one frame from the background spectrum (or a flat one) scaled by gain
+func (cn *ComfortNoise) generate(dst []int16, gain float64, flat bool) {
+	n := len(cn.spectrum)
+	// Random phase and Rayleigh magnitude per bin; the real part of the inverse
+	// transform carries half of the power, hence 2n
+	scale := math.Sqrt(2 * float64(n) * gain / cn.windowSum)
+	for i, p := range cn.psd {
+		if flat {
+			p = 1
+		}
+		amp := scale * math.Sqrt(p/2)
+		cn.spectrum[i] = complex(cn.rng.NormFloat64()*amp, cn.rng.NormFloat64()*amp)
+	}
+	IFFT(cn.spectrum)
+
+	// Equal-power crossfade from the previous block hides the block boundary
+	overlap := len(cn.tail)
+	for i := range dst {
+		v := real(cn.spectrum[i])
+		if i < overlap {
+			t := (float64(i) + 0.5) / float64(overlap) * math.Pi / 2
+			v = v*math.Sin(t) + cn.tail[i]*math.Cos(t)
+		}
+		dst[i] = clampInt16(v)
+	}
+	for i := range cn.tail {
+		cn.tail[i] = real(cn.spectrum[len(dst)+i])
+	}
+}
+
+// Fill tops up a suppressed frame to the comfort noise level and reports whether noise was added
+// Frames already at or above the level are left unchanged
+func (cn *ComfortNoise) Fill(frame []int16) bool {
+	target := cn.Power()
+	if target <= 0 {
+		return false
+	}
+	power := 0.0
+	for _, v := range frame {
+		power += float64(v) * float64(v)
+	}
+	power /= float64(len(frame))
+	if power >= target {
+		return false
+	}
+
+	// The noise is uncorrelated with the frame, so the powers add
+	cn.Generate(cn.noise[:len(frame)])
+	gain := math.Sqrt(1 - power/target)
+	for i, v := range frame {
+		frame[i] = clampInt16(float64(v) + gain*float64(cn.noise[i]))
+	}
+	return true
+}
+
+// clampInt16 rounds and saturates a sample to the int16 range
+func clampInt16(v float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(v))))
+}
@@ -0,0 +1,176 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// power returns the mean square of s
+func power(s []int16) float64 {
+	sum := 0.0
+	for _, v := range s {
+		sum += float64(v) * float64(v)
+	}
+	return sum / float64(len(s))
+}
+
+// highFraction returns the share of power in the first difference of s, a rough high-frequency measure
+func highFraction(s []int16) float64 {
+	diff := make([]int16, len(s)-1)
+	for i := range diff {
+		diff[i] = int16((int(s[i+1]) - int(s[i])) / 2)
+	}
+	return power(diff) / power(s)
+}
+
+// lowpass returns s smoothed by a moving average of width taps
+func lowpass(s []int16, taps int) []int16 {
+	out := make([]int16, len(s))
+	for i := range out {
+		sum := 0
+		for j := max(i-taps+1, 0); j <= i; j++ {
+			sum += int(s[j])
+		}
+		out[i] = int16(sum / taps)
+	}
+	return out
+}
+
+func TestComfortNoise_Generate(t *testing.T) {
+	const frameSize = 320
+	white := noise(frameSize*200, 1)
+
+	tests := []struct {
+		name     string
+		input    []int16
+		offsetDB float64
+	}{
+		{name: "white", input: white, offsetDB: 0},
+		{name: "low-pass", input: lowpass(white, 8), offsetDB: 0},
+		{name: "offset", input: white, offsetDB: -10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cn := NewComfortNoise(frameSize, tt.offsetDB, 2)
+			for i := 0; i+frameSize <= len(tt.input); i += frameSize {
+				cn.Update(tt.input[i : i+frameSize])
+			}
+
+			out := make([]int16, frameSize*100)
+			for i := 0; i < len(out); i += frameSize {
+				cn.Generate(out[i : i+frameSize])
+			}
+
+			gotDB := 10 * math.Log10(power(out)/power(tt.input))
+			if math.Abs(gotDB-tt.offsetDB) > 1 {
+				t.Errorf("level = %.1f dB relative to input, want about %.1f dB", gotDB, tt.offsetDB)
+			}
+			if got, want := highFraction(out), highFraction(tt.input); math.Abs(got-want) > 0.25*want {
+				t.Errorf("high-frequency share = %.3f, want %.3f", got, want)
+			}
+			if math.Abs(cn.Power()-power(out)) > 0.1*power(out) {
+				t.Errorf("Power() = %.0f, generated %.0f", cn.Power(), power(out))
+			}
+		})
+	}
+}
+
+func TestComfortNoise_GenerateLevel(t *testing.T) {
+	const frameSize = 320
+	shaped := lowpass(noise(frameSize*200, 1), 8)
+
+	tests := []struct {
+		name  string
+		input []int16 // background seen before generating, nil for none
+		dBFS  float64
+	}{
+		{name: "no background", input: nil, dBFS: -60},
+		{name: "shaped background", input: shaped, dBFS: -40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cn := NewComfortNoise(frameSize, 10, 2)
+			for i := 0; i+frameSize <= len(tt.input); i += frameSize {
+				cn.Update(tt.input[i : i+frameSize])
+			}
+
+			out := make([]int16, frameSize*100)
+			for i := 0; i < len(out); i += frameSize {
+				cn.GenerateLevel(out[i:i+frameSize], tt.dBFS)
+			}
+
+			// The fixed level ignores both the background level and the offset
+			gotDB := 10 * math.Log10(power(out)/(32768*32768))
+			if math.Abs(gotDB-tt.dBFS) > 1 {
+				t.Errorf("level = %.1f dBFS, want about %.1f dBFS", gotDB, tt.dBFS)
+			}
+			if tt.input != nil {
+				if got, want := highFraction(out), highFraction(tt.input); math.Abs(got-want) > 0.25*want {
+					t.Errorf("high-frequency share = %.3f, want %.3f", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComfortNoise_Fill(t *testing.T) {
+	const frameSize = 160
+	cn := NewComfortNoise(frameSize, 0, 1)
+
+	silent := make([]int16, frameSize)
+	if cn.Fill(silent) {
+		t.Errorf("Fill() added noise before any background estimate")
+	}
+
+	background := noise(frameSize*50, 3)
+	for i := 0; i < len(background); i += frameSize {
+		cn.Update(background[i : i+frameSize])
+	}
+
+	if !cn.Fill(silent) || power(silent) == 0 {
+		t.Errorf("Fill() left a silent frame silent")
+	}
+
+	loud := noise(frameSize, 4)
+	for i := range loud {
+		loud[i] *= 3
+	}
+	original := append([]int16(nil), loud...)
+	if cn.Fill(loud) {
+		t.Errorf("Fill() added noise to a frame above the comfort noise level")
+	}
+	for i := range loud {
+		if loud[i] != original[i] {
+			t.Fatalf("Fill() modified a loud frame at sample %d", i)
+		}
+	}
+}
+
+func TestComfortNoise_IgnoresBursts(t *testing.T) {
+	const frameSize = 320
+	background := noise(frameSize*300, 5)
+	for i := range background {
+		background[i] /= 20
+	}
+
+	// Loud bursts in half of the frames must not raise the background estimate
+	input := append([]int16(nil), background...)
+	bursts := noise(len(input), 6)
+	for frame := 0; frame < len(input)/frameSize; frame++ {
+		if frame/10%2 == 1 {
+			for i := frame * frameSize; i < (frame+1)*frameSize; i++ {
+				input[i] = bursts[i]
+			}
+		}
+	}
+
+	cn := NewComfortNoise(frameSize, 0, 1)
+	for i := 0; i < len(input); i += frameSize {
+		cn.Update(input[i : i+frameSize])
+	}
+	if gotDB := 10 * math.Log10(cn.Power()/power(background)); math.Abs(gotDB) > 1.5 {
+		t.Errorf("estimate = %.1f dB relative to the background, want about 0 dB", gotDB)
+	}
+}
@@ -0,0 +1,89 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// DelayEstimator finds the bulk delay between a reference and a delayed signal
+// using the generalized cross-correlation with phase transform (GCC-PHAT)
+type DelayEstimator struct {
+	window int
+	maxLag int
+	ref    []complex128
+	sig    []complex128
+}
+
+// NewDelayEstimator creates a delay estimator
+// window: maximum number of samples analysed per estimate
+// maxLag: largest delay in samples searched in either direction
+func NewDelayEstimator(window, maxLag int) *DelayEstimator {
+	n := NextPowerOfTwo(window + maxLag)
+	return &DelayEstimator{
+		window: window,
+		maxLag: maxLag,
+		ref:    make([]complex128, n),
+		sig:    make([]complex128, n),
+	}
+}
+
+// Estimate returns the lag in samples by which sig trails ref (negative if sig leads)
+// and a confidence score: the correlation peak relative to the RMS of the searched lags
+// Only the last window samples of each input are used; silent input yields zero confidence
+func (de *DelayEstimator) Estimate(ref, sig []int16) (lag int, confidence float64) {
+	ref = tail(ref, de.window)
+	sig = tail(sig, de.window)
+	load(de.ref, ref)
+	load(de.sig, sig)
+
+	FFT(de.ref)
+	FFT(de.sig)
+
+	// Cross-power spectrum with PHAT weighting keeps only the phase
+	for i := range de.sig {
+		cross := de.sig[i] * cmplx.Conj(de.ref[i])
+		if mag := cmplx.Abs(cross); mag > 1e-12 {
+			de.sig[i] = cross / complex(mag, 0)
+		} else {
+			de.sig[i] = 0
+		}
+	}
+	IFFT(de.sig)
+
+	n := len(de.sig)
+	peak := 0.0
+	sumSquares := 0.0
+	for l := -de.maxLag; l <= de.maxLag; l++ {
+		v := real(de.sig[(l+n)%n])
+		sumSquares += v * v
+		if v > peak {
+			peak = v
+			lag = l
+		}
+	}
+
+	rms := math.Sqrt(sumSquares / float64(2*de.maxLag+1))
+	if peak <= 0 || rms == 0 {
+		return 0, 0
+	}
+	return lag, peak / rms
+}
+
+// tail returns the last n samples of s
+func tail(s []int16, n int) []int16 {
+	if len(s) > n {
+		return s[len(s)-n:]
+	}
+	return s
+}
+
+// load copies samples into a zero-padded complex buffer
+func load(dst []complex128, src []int16) {
+	for i := range dst {
+		if i < len(src) {
+			dst[i] = complex(float64(src[i]), 0)
+		} else {
+			dst[i] = 0
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package dsp
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// noise returns deterministic white noise
+func noise(n int, seed int64) []int16 {
+	rng := rand.New(rand.NewSource(seed))
+	s := make([]int16, n)
+	for i := range s {
+		s[i] = int16(rng.Intn(16000) - 8000)
+	}
+	return s
+}
+
+// delayed returns s delayed by d samples (negative d advances it), scaled by gain
+func delayed(s []int16, d int, gain float64) []int16 {
+	out := make([]int16, len(s))
+	for i := range out {
+		if j := i - d; j >= 0 && j < len(s) {
+			out[i] = int16(float64(s[j]) * gain)
+		}
+	}
+	return out
+}
+
+func TestDelayEstimator_Estimate(t *testing.T) {
+	ref := noise(8000, 1)
+
+	tests := []struct {
+		name  string
+		delay int
+		gain  float64
+	}{
+		{name: "no delay", delay: 0, gain: 1},
+		{name: "mic trails speaker", delay: 800, gain: 0.5},
+		{name: "short delay", delay: 37, gain: 0.3},
+		{name: "mic leads speaker", delay: -120, gain: 0.8},
+	}
+
+	de := NewDelayEstimator(len(ref), 1600)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := delayed(ref, tt.delay, tt.gain)
+			// Add uncorrelated noise to the delayed signal
+			for i, n := range noise(len(sig), 2) {
+				sig[i] += n / 8
+			}
+
+			lag, confidence := de.Estimate(ref, sig)
+			if lag != tt.delay {
+				t.Errorf("Estimate() lag = %d, expected %d", lag, tt.delay)
+			}
+			if confidence < 10 {
+				t.Errorf("Estimate() confidence = %.2f, expected a clear peak", confidence)
+			}
+		})
+	}
+}
+
+func TestDelayEstimator_Silence(t *testing.T) {
+	de := NewDelayEstimator(1024, 100)
+	lag, confidence := de.Estimate(make([]int16, 1024), noise(1024, 3))
+	if lag != 0 || confidence != 0 {
+		t.Errorf("Estimate() on silent reference = (%d, %.2f), expected (0, 0)", lag, confidence)
+	}
+}
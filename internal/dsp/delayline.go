@@ -0,0 +1,41 @@
+package dsp
+
+// DelayLine delays a sample stream by a configurable number of samples
+type DelayLine struct {
+	buf   []int16
+	pos   int
+	delay int
+}
+
+// NewDelayLine creates a delay line supporting delays up to maxDelay samples
+func NewDelayLine(maxDelay int) *DelayLine {
+	return &DelayLine{
+		buf: make([]int16, max(maxDelay, 0)+1),
+	}
+}
+
+// SetDelay changes the delay in samples, clamped to [0, MaxDelay]
+func (dl *DelayLine) SetDelay(delay int) {
+	dl.delay = min(max(delay, 0), dl.MaxDelay())
+}
+
+// Delay returns the current delay in samples
+func (dl *DelayLine) Delay() int {
+	return dl.delay
+}
+
+// MaxDelay returns the largest supported delay in samples
+func (dl *DelayLine) MaxDelay() int {
+	return len(dl.buf) - 1
+}
+
+// Process pushes src into the line and writes the delayed samples to dst
+// dst and src may be the same slice
+func (dl *DelayLine) Process(dst, src []int16) {
+	size := len(dl.buf)
+	for i, sample := range src {
+		dl.buf[dl.pos] = sample
+		dst[i] = dl.buf[(dl.pos-dl.delay+size)%size]
+		dl.pos = (dl.pos + 1) % size
+	}
+}
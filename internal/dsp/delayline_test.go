@@ -0,0 +1,35 @@
+package dsp
+
+import "testing"
+
+func TestDelayLine_Process(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDelay int
+		delay    int
+		expected []int16
+	}{
+		{name: "no delay", maxDelay: 4, delay: 0, expected: []int16{1, 2, 3, 4, 5, 6}},
+		{name: "two samples", maxDelay: 4, delay: 2, expected: []int16{0, 0, 1, 2, 3, 4}},
+		{name: "clamped to max", maxDelay: 3, delay: 10, expected: []int16{0, 0, 0, 1, 2, 3}},
+		{name: "negative clamped to zero", maxDelay: 3, delay: -1, expected: []int16{1, 2, 3, 4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := NewDelayLine(tt.maxDelay)
+			dl.SetDelay(tt.delay)
+
+			// Feed the input in two blocks to cover wrap-around between calls
+			buf := []int16{1, 2, 3, 4, 5, 6}
+			dl.Process(buf[:4], buf[:4])
+			dl.Process(buf[4:], buf[4:])
+
+			for i := range tt.expected {
+				if buf[i] != tt.expected[i] {
+					t.Fatalf("Process() = %v, expected %v", buf, tt.expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package dsp
+
+// DriftTracker measures clock drift between two streams as the trend of their delay
+// estimates over time, using a least-squares line fit
+type DriftTracker struct {
+	n                        int
+	sumT, sumL, sumTT, sumTL float64
+}
+
+// minDriftPoints is the number of delay estimates needed before drift is reported
+const minDriftPoints = 3
+
+// Add records a delay estimate of lag samples measured at time t (in samples)
+func (dt *DriftTracker) Add(t, lag int) {
+	ft, fl := float64(t), float64(lag)
+	dt.n++
+	dt.sumT += ft
+	dt.sumL += fl
+	dt.sumTT += ft * ft
+	dt.sumTL += ft * fl
+}
+
+// Points returns the number of recorded delay estimates
+func (dt *DriftTracker) Points() int {
+	return dt.n
+}
+
+// PPM returns the drift in parts per million (positive if the delay grows over time)
+// ok is false until enough estimates spread over time have been recorded
+func (dt *DriftTracker) PPM() (ppm float64, ok bool) {
+	if dt.n < minDriftPoints {
+		return 0, false
+	}
+	n := float64(dt.n)
+	denom := n*dt.sumTT - dt.sumT*dt.sumT
+	if denom <= 0 {
+		return 0, false
+	}
+	slope := (n*dt.sumTL - dt.sumT*dt.sumL) / denom
+	return slope * 1e6, true
+}
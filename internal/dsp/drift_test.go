@@ -0,0 +1,38 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDriftTracker_PPM(t *testing.T) {
+	tests := []struct {
+		name   string
+		points [][2]int // time, lag
+		want   float64
+		wantOK bool
+	}{
+		{name: "no estimates", wantOK: false},
+		{name: "too few estimates", points: [][2]int{{0, 100}, {16000, 101}}, wantOK: false},
+		{name: "same time", points: [][2]int{{8000, 100}, {8000, 101}, {8000, 102}}, wantOK: false},
+		{name: "constant delay", points: [][2]int{{0, 100}, {16000, 100}, {32000, 100}}, want: 0, wantOK: true},
+		{name: "growing delay", points: [][2]int{{0, 100}, {100000, 102}, {200000, 104}, {300000, 106}}, want: 20, wantOK: true},
+		{name: "shrinking delay", points: [][2]int{{0, 100}, {1000000, 50}, {2000000, 0}}, want: -50, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dt DriftTracker
+			for _, p := range tt.points {
+				dt.Add(p[0], p[1])
+			}
+			ppm, ok := dt.PPM()
+			if ok != tt.wantOK {
+				t.Fatalf("PPM() ok = %v, expected %v", ok, tt.wantOK)
+			}
+			if ok && math.Abs(ppm-tt.want) > 1e-6 {
+				t.Errorf("PPM() = %f, expected %f", ppm, tt.want)
+			}
+		})
+	}
+}
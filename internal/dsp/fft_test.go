@@ -0,0 +1,69 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestNextPowerOfTwo(t *testing.T) {
+	tests := []struct {
+		input    int
+		expected int
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 2},
+		{3, 4},
+		{320, 512},
+		{1024, 1024},
+		{1025, 2048},
+	}
+
+	for _, tt := range tests {
+		if got := NextPowerOfTwo(tt.input); got != tt.expected {
+			t.Errorf("NextPowerOfTwo(%d) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+	for _, n := range []int{1, 2, 8, 64} {
+		x := make([]complex128, n)
+		for i := range x {
+			x[i] = complex(math.Sin(float64(i)*0.7)+float64(i%3), math.Cos(float64(i)*1.3))
+		}
+
+		// Naive DFT for reference
+		want := make([]complex128, n)
+		for k := range want {
+			for i, v := range x {
+				want[k] += v * cmplx.Rect(1, -2*math.Pi*float64(k*i)/float64(n))
+			}
+		}
+
+		got := append([]complex128(nil), x...)
+		FFT(got)
+		for k := range want {
+			if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+				t.Fatalf("n=%d: FFT bin %d = %v, expected %v", n, k, got[k], want[k])
+			}
+		}
+
+		IFFT(got)
+		for i := range x {
+			if cmplx.Abs(got[i]-x[i]) > 1e-9 {
+				t.Fatalf("n=%d: IFFT(FFT(x))[%d] = %v, expected %v", n, i, got[i], x[i])
+			}
+		}
+	}
+}
+
+func TestFFTPanicsOnInvalidLength(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("FFT() with length 6 did not panic")
+		}
+	}()
+	FFT(make([]complex128, 6))
+}
@@ -0,0 +1,102 @@
+package metrics
+
+// Echo path diagnostics from the impulse response of the adaptive filter
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+
+	"open_tool_speex/pkg/audio"
+)
+
+// ImpulseUnity is the raw impulse response value of unity gain
+const ImpulseUnity = 32767.0
+
+// TailFraction is the part at the end of the filter checked by TailEnergy
+const TailFraction = 0.25
+
+// ImpulseResponse is a snapshot of the echo path learned by the echo canceller
+type ImpulseResponse struct {
+	SampleRate int
+	Taps       []float64 // Filter coefficients, 1.0 = unity gain
+}
+
+// NewImpulseResponse converts the raw filter taps of the echo canceller
+func NewImpulseResponse(sampleRate int, raw []int32) *ImpulseResponse {
+	taps := make([]float64, len(raw))
+	for i, v := range raw {
+		taps[i] = float64(v) / ImpulseUnity
+	}
+	return &ImpulseResponse{SampleRate: sampleRate, Taps: taps}
+}
+
+// Peak returns the tap with the largest magnitude (-1 if the response is empty)
+func (ir *ImpulseResponse) Peak() int {
+	peak := -1
+	for i, v := range ir.Taps {
+		if peak < 0 || math.Abs(v) > math.Abs(ir.Taps[peak]) {
+			peak = i
+		}
+	}
+	return peak
+}
+
+// TapMs returns the echo delay of a tap in milliseconds
+func (ir *ImpulseResponse) TapMs(tap int) float64 {
+	return float64(tap) * 1000 / float64(ir.SampleRate)
+}
+
+// TailEnergy returns the share of the energy in the last TailFraction of the filter
+// A large share means the echo lasts longer than the filter (-echo-tail is too short)
+func (ir *ImpulseResponse) TailEnergy() float64 {
+	start := len(ir.Taps) - int(float64(len(ir.Taps))*TailFraction)
+	total, tail := 0.0, 0.0
+	for i, v := range ir.Taps {
+		total += v * v
+		if i >= start {
+			tail += v * v
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return tail / total
+}
+
+// WriteCSV writes one row per tap
+func (ir *ImpulseResponse) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"tap", "time_ms", "coefficient"}); err != nil {
+		return err
+	}
+	for i, v := range ir.Taps {
+		if err := cw.Write([]string{
+			strconv.Itoa(i),
+			strconv.FormatFloat(ir.TapMs(i), 'f', 4, 64),
+			strconv.FormatFloat(v, 'g', 8, 64),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteWAV writes the response as a mono 32-bit float WAV at the processing sample rate
+func (ir *ImpulseResponse) WriteWAV(w io.Writer) error {
+	ww, err := audio.NewWAVWriter(w, audio.WAVFormatIEEEFloat, ir.SampleRate, 1)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 4*len(ir.Taps))
+	for i, v := range ir.Taps {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(v)))
+	}
+	if _, err := ww.Write(data); err != nil {
+		return err
+	}
+	return ww.Close()
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"open_tool_speex/pkg/audio"
+)
+
+func TestImpulseResponse_Peak(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      []int32
+		wantPeak int
+		wantTail float64
+	}{
+		{name: "empty", raw: nil, wantPeak: -1, wantTail: 0},
+		{name: "direct path", raw: []int32{0, 16384, 0, 0, 0, 0, 0, 0}, wantPeak: 1, wantTail: 0},
+		{name: "negative peak", raw: []int32{100, 0, -20000, 0, 0, 0, 0, 0}, wantPeak: 2, wantTail: 0},
+		{name: "echo beyond the filter", raw: []int32{0, 0, 0, 0, 0, 0, 1000, 1000}, wantPeak: 6, wantTail: 1},
+		{name: "half in the tail", raw: []int32{0, 1000, 0, 0, 0, 0, 0, 1000}, wantPeak: 1, wantTail: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir := NewImpulseResponse(16000, tt.raw)
+			if got := ir.Peak(); got != tt.wantPeak {
+				t.Errorf("Peak() = %d, want %d", got, tt.wantPeak)
+			}
+			if got := ir.TailEnergy(); math.Abs(got-tt.wantTail) > 1e-9 {
+				t.Errorf("TailEnergy() = %f, want %f", got, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestImpulseResponse_WriteCSV(t *testing.T) {
+	ir := NewImpulseResponse(8000, []int32{0, 32767, -16384})
+	var buf bytes.Buffer
+	if err := ir.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"tap,time_ms,coefficient",
+		"0,0.0000,0",
+		"1,0.1250,1",
+		"2,0.2500,-0.50001526",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("WriteCSV() lines = %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestImpulseResponse_WriteWAV(t *testing.T) {
+	ir := NewImpulseResponse(16000, []int32{32767, 0, -32767})
+	var buf bytes.Buffer
+	if err := ir.WriteWAV(&buf); err != nil {
+		t.Fatalf("WriteWAV() error = %v", err)
+	}
+
+	header, err := audio.ReadWAVHeader(&buf)
+	if err != nil {
+		t.Fatalf("ReadWAVHeader() error = %v", err)
+	}
+	if header.AudioFormat != audio.WAVFormatIEEEFloat || header.NumChannels != 1 || header.SampleRate != 16000 {
+		t.Fatalf("header = %+v, want mono float at 16000 Hz", header)
+	}
+	samples := make([]float32, 3)
+	if err := binary.Read(&buf, binary.LittleEndian, samples); err != nil {
+		t.Fatalf("reading samples: %v", err)
+	}
+	for i, want := range []float32{1, 0, -1} {
+		if samples[i] != want {
+			t.Errorf("sample %d = %f, want %f", i, samples[i], want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package metrics
+
+// Echo cancellation metrics: per-frame levels, activity and Echo Return Loss Enhancement (ERLE)
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	// SilenceDB is the level reported for frames without energy
+	SilenceDB = -120.0
+	// ActivityThresholdDB is the level above which a stream is considered active
+	ActivityThresholdDB = -50.0
+	// GeigelMarginDB is how close the mic may get to the reference during far-end activity
+	// before the frame is classified as double talk (the echo path is assumed to attenuate more)
+	GeigelMarginDB = 6.0
+)
+
+// FrameStats holds the metrics of one frame
+type FrameStats struct {
+	Index      int
+	TimeSec    float64
+	MicDB      float64 // Mic level before processing (dBFS)
+	SpeakerDB  float64 // Speaker reference level (dBFS)
+	OutputDB   float64 // Output level after processing (dBFS)
+	FarEnd     bool    // Speaker reference active
+	NearEnd    bool    // Near-end speech present in the mic
+	ERLE       float64 // Mic to output level reduction in dB (NaN unless far-end only)
+	energyMic  float64
+	energyOut  float64
+	energySpkr float64
+}
+
+// FarEndOnly reports whether only the far end is active, the condition under which ERLE is measured
+func (fs FrameStats) FarEndOnly() bool {
+	return fs.FarEnd && !fs.NearEnd
+}
+
+// Activity counts frames by talker activity
+type Activity struct {
+	FarEndOnly  int `json:"far_end_only"`
+	NearEndOnly int `json:"near_end_only"`
+	DoubleTalk  int `json:"double_talk"`
+	Silence     int `json:"silence"`
+}
+
+// Summary holds the aggregate metrics of a run
+type Summary struct {
+	SampleRate  int     `json:"sample_rate"`
+	FrameSize   int     `json:"frame_size"`
+	Frames      int     `json:"frames"`
+	DurationSec float64 `json:"duration_sec"`
+
+	// ERLE over far-end-only frames (nil if there were none)
+	ERLE       *float64 `json:"erle_db"`
+	ERLEFrames int      `json:"erle_frames"`
+	// Output level during far-end-only frames (nil if there were none)
+	ResidualEchoDB *float64 `json:"residual_echo_dbfs"`
+
+	MicDB     float64 `json:"mic_dbfs"`
+	SpeakerDB float64 `json:"speaker_dbfs"`
+	OutputDB  float64 `json:"output_dbfs"`
+
+	Activity Activity `json:"activity"`
+}
+
+// Collector accumulates per-frame echo metrics and optionally writes them as CSV
+type Collector struct {
+	sampleRate int
+	frameSize  int
+	frames     int
+	csv        *csv.Writer
+
+	micEnergy, speakerEnergy, outputEnergy float64 // totals over all frames
+	erleMic, erleOut                       float64 // totals over far-end-only frames
+	erleFrames                             int
+	activity                               Activity
+}
+
+// NewCollector creates a metrics collector; per-frame rows are written to csvOut if it is not nil
+func NewCollector(sampleRate, frameSize int, csvOut io.Writer) *Collector {
+	c := &Collector{
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+	}
+	if csvOut != nil {
+		c.csv = csv.NewWriter(csvOut)
+		c.csv.Write([]string{"frame", "time_sec", "mic_dbfs", "speaker_dbfs", "output_dbfs", "far_end", "near_end", "erle_db"})
+	}
+	return c
+}
+
+// Add records one frame: the mic and speaker frames fed to the echo canceller and its output
+func (c *Collector) Add(mic, speaker, output []int16) (FrameStats, error) {
+	fs := Analyze(mic, speaker, output)
+	fs.Index = c.frames
+	fs.TimeSec = float64(c.frames*c.frameSize) / float64(c.sampleRate)
+	c.frames++
+
+	c.micEnergy += fs.energyMic
+	c.speakerEnergy += fs.energySpkr
+	c.outputEnergy += fs.energyOut
+
+	switch {
+	case fs.FarEndOnly():
+		c.activity.FarEndOnly++
+		c.erleMic += fs.energyMic
+		c.erleOut += fs.energyOut
+		c.erleFrames++
+	case fs.FarEnd && fs.NearEnd:
+		c.activity.DoubleTalk++
+	case fs.NearEnd:
+		c.activity.NearEndOnly++
+	default:
+		c.activity.Silence++
+	}
+
+	if c.csv != nil {
+		erle := ""
+		if !math.IsNaN(fs.ERLE) {
+			erle = formatDB(fs.ERLE)
+		}
+		c.csv.Write([]string{
+			strconv.Itoa(fs.Index),
+			strconv.FormatFloat(fs.TimeSec, 'f', 3, 64),
+			formatDB(fs.MicDB),
+			formatDB(fs.SpeakerDB),
+			formatDB(fs.OutputDB),
+			strconv.FormatBool(fs.FarEnd),
+			strconv.FormatBool(fs.NearEnd),
+			erle,
+		})
+		if err := c.csv.Error(); err != nil {
+			return fs, err
+		}
+	}
+	return fs, nil
+}
+
+// Flush writes buffered CSV rows
+func (c *Collector) Flush() error {
+	if c.csv == nil {
+		return nil
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// Summary returns the aggregate metrics
+func (c *Collector) Summary() Summary {
+	samples := c.frames * c.frameSize
+	s := Summary{
+		SampleRate:  c.sampleRate,
+		FrameSize:   c.frameSize,
+		Frames:      c.frames,
+		DurationSec: float64(samples) / float64(c.sampleRate),
+		ERLEFrames:  c.erleFrames,
+		MicDB:       energyDB(c.micEnergy, samples),
+		SpeakerDB:   energyDB(c.speakerEnergy, samples),
+		OutputDB:    energyDB(c.outputEnergy, samples),
+		Activity:    c.activity,
+	}
+	if c.erleFrames > 0 {
+		erle := energyDB(c.erleMic, 1) - energyDB(c.erleOut, 1)
+		residual := energyDB(c.erleOut, c.erleFrames*c.frameSize)
+		s.ERLE = &erle
+		s.ResidualEchoDB = &residual
+	}
+	return s
+}
+
+// WriteJSON writes the summary as indented JSON
+func (c *Collector) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Summary())
+}
+
+// Analyze computes levels, activity and ERLE of a single frame
+func Analyze(mic, speaker, output []int16) FrameStats {
+	fs := FrameStats{
+		energyMic:  energy(mic),
+		energySpkr: energy(speaker),
+		energyOut:  energy(output),
+	}
+	fs.MicDB = energyDB(fs.energyMic, len(mic))
+	fs.SpeakerDB = energyDB(fs.energySpkr, len(speaker))
+	fs.OutputDB = energyDB(fs.energyOut, len(output))
+
+	fs.FarEnd = fs.SpeakerDB > ActivityThresholdDB
+	micActive := fs.MicDB > ActivityThresholdDB
+	// Geigel-style double-talk detection: echo alone stays well below the reference
+	fs.NearEnd = micActive && (!fs.FarEnd || fs.MicDB > fs.SpeakerDB-GeigelMarginDB)
+
+	fs.ERLE = math.NaN()
+	if fs.FarEndOnly() {
+		fs.ERLE = fs.MicDB - fs.OutputDB
+	}
+	return fs
+}
+
+// energy returns the sum of squared samples
+func energy(frame []int16) float64 {
+	var sum float64
+	for _, s := range frame {
+		v := float64(s)
+		sum += v * v
+	}
+	return sum
+}
+
+// energyDB converts a total energy over n samples to a level in dBFS
+func energyDB(total float64, n int) float64 {
+	if total <= 0 || n <= 0 {
+		return SilenceDB
+	}
+	db := 10 * math.Log10(total/float64(n)/(32768*32768))
+	return math.Max(db, SilenceDB)
+}
+
+// formatDB formats a level with two decimals
+func formatDB(db float64) string {
+	return strconv.FormatFloat(db, 'f', 2, 64)
+}
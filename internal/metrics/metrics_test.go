@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// tone returns a frame with a square wave of the given amplitude
+func tone(n int, amplitude int16) []int16 {
+	frame := make([]int16, n)
+	for i := range frame {
+		if i%2 == 0 {
+			frame[i] = amplitude
+		} else {
+			frame[i] = -amplitude
+		}
+	}
+	return frame
+}
+
+func TestAnalyze(t *testing.T) {
+	const n = 160
+	tests := []struct {
+		name        string
+		mic         int16
+		speaker     int16
+		output      int16
+		wantFarEnd  bool
+		wantNearEnd bool
+		wantERLE    float64 // NaN if not measured
+	}{
+		{name: "silence", mic: 0, speaker: 0, output: 0, wantERLE: math.NaN()},
+		{name: "far end only", mic: 1000, speaker: 8000, output: 100, wantFarEnd: true, wantERLE: 20},
+		{name: "near end only", mic: 4000, speaker: 0, output: 4000, wantNearEnd: true, wantERLE: math.NaN()},
+		{name: "double talk", mic: 8000, speaker: 8000, output: 6000, wantFarEnd: true, wantNearEnd: true, wantERLE: math.NaN()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := Analyze(tone(n, tt.mic), tone(n, tt.speaker), tone(n, tt.output))
+			if fs.FarEnd != tt.wantFarEnd || fs.NearEnd != tt.wantNearEnd {
+				t.Errorf("Analyze() activity = far %v near %v, expected far %v near %v", fs.FarEnd, fs.NearEnd, tt.wantFarEnd, tt.wantNearEnd)
+			}
+			if math.IsNaN(tt.wantERLE) != math.IsNaN(fs.ERLE) || (!math.IsNaN(fs.ERLE) && math.Abs(fs.ERLE-tt.wantERLE) > 0.01) {
+				t.Errorf("Analyze() ERLE = %.2f, expected %.2f", fs.ERLE, tt.wantERLE)
+			}
+		})
+	}
+}
+
+func TestCollector_Summary(t *testing.T) {
+	const n = 160
+	var csvOut bytes.Buffer
+	c := NewCollector(8000, n, &csvOut)
+
+	// Two far-end-only frames with 20 dB and 40 dB reduction, one near-end frame
+	frames := [][3]int16{
+		{1000, 8000, 100},
+		{1000, 8000, 10},
+		{4000, 0, 4000},
+	}
+	for _, f := range frames {
+		if _, err := c.Add(tone(n, f[0]), tone(n, f[1]), tone(n, f[2])); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	s := c.Summary()
+	if s.Frames != 3 || s.ERLEFrames != 2 {
+		t.Errorf("Summary() frames = %d/%d, expected 3/2", s.Frames, s.ERLEFrames)
+	}
+	// Energy-weighted: 10*log10(2 / (0.01 + 0.0001))
+	wantERLE := 10 * math.Log10(2/(0.01+0.0001))
+	if s.ERLE == nil || math.Abs(*s.ERLE-wantERLE) > 0.01 {
+		t.Errorf("Summary() ERLE = %v, expected %.2f", s.ERLE, wantERLE)
+	}
+	if s.Activity != (Activity{FarEndOnly: 2, NearEndOnly: 1}) {
+		t.Errorf("Summary() activity = %+v", s.Activity)
+	}
+
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("CSV has %d lines, expected header and 3 rows", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "frame,time_sec,") || !strings.HasSuffix(lines[3], ",") {
+		t.Errorf("CSV = %q", csvOut.String())
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded Summary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() produced invalid JSON: %v", err)
+	}
+	if decoded.Frames != 3 || decoded.ERLE == nil {
+		t.Errorf("decoded summary = %+v", decoded)
+	}
+}
+
+func TestCollector_NoFarEnd(t *testing.T) {
+	c := NewCollector(16000, 320, nil)
+	c.Add(make([]int16, 320), make([]int16, 320), make([]int16, 320))
+
+	s := c.Summary()
+	if s.ERLE != nil || s.ResidualEchoDB != nil {
+		t.Errorf("Summary() without far-end frames has ERLE %v, residual %v", s.ERLE, s.ResidualEchoDB)
+	}
+	if s.MicDB != SilenceDB {
+		t.Errorf("Summary() MicDB = %.1f, expected %.1f", s.MicDB, SilenceDB)
+	}
+}
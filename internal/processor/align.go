@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"open_tool_speex/internal/dsp"
+)
+
+// minDelayConfidence is the GCC-PHAT peak-to-RMS ratio below which an estimate is ignored
+const minDelayConfidence = 6.0
+
+// frameSource delivers synchronized mic and speaker frames
+type frameSource interface {
+	readFrame(mic, speaker []int16) error
+}
+
+// bufferedFrame is a mic/speaker frame pair read ahead of processing
+type bufferedFrame struct {
+	mic, speaker []int16
+}
+
+// delayAligner estimates the speaker-to-mic delay and shifts the speaker stream to match
+// The first estimate is made on a window read ahead of processing; later ones on the
+// most recent window, every interval samples
+type delayAligner struct {
+	source     frameSource
+	estimator  *dsp.DelayEstimator
+	stage      *delayStage
+	sampleRate int
+	window     int // analysis window in samples
+	interval   int // re-estimation interval in samples (0 = estimate once)
+	micHist    []int16
+	spkHist    []int16
+	sinceLast  int
+	attempted  bool
+	pending    []bufferedFrame
+}
+
+// newDelayAligner creates an aligner reading frames from source
+func (p *Processor) newDelayAligner(source frameSource) *delayAligner {
+	maxDelay := p.config.SampleRate * p.config.MaxDelayMs / 1000
+	window := int(p.config.DelayWindowSec * float64(p.config.SampleRate))
+	return &delayAligner{
+		source:     source,
+		estimator:  dsp.NewDelayEstimator(window, maxDelay),
+		stage:      newDelayStage(maxDelay, p.config.FrameSize),
+		sampleRate: p.config.SampleRate,
+		window:     window,
+		interval:   int(p.config.DelayUpdateSec * float64(p.config.SampleRate)),
+		micHist:    make([]int16, 0, window),
+		spkHist:    make([]int16, 0, window),
+	}
+}
+
+// prime reads the first analysis window ahead of processing and makes the initial estimate
+func (da *delayAligner) prime(frameSize int) error {
+	for len(da.micHist) < da.window {
+		frame := bufferedFrame{mic: make([]int16, frameSize), speaker: make([]int16, frameSize)}
+		err := da.source.readFrame(frame.mic, frame.speaker)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		da.pending = append(da.pending, frame)
+		da.record(frame.mic, frame.speaker)
+	}
+
+	da.estimate()
+	da.sinceLast = 0
+	return nil
+}
+
+// readFrame returns read-ahead frames first, then frames from the source,
+// re-estimating the delay when the interval has elapsed
+func (da *delayAligner) readFrame(mic, speaker []int16) error {
+	if len(da.pending) > 0 {
+		copy(mic, da.pending[0].mic)
+		copy(speaker, da.pending[0].speaker)
+		da.pending = da.pending[1:]
+		return nil
+	}
+
+	if err := da.source.readFrame(mic, speaker); err != nil {
+		return err
+	}
+	da.record(mic, speaker)
+
+	if da.interval > 0 {
+		da.sinceLast += len(mic)
+		if da.sinceLast >= da.interval {
+			da.sinceLast = 0
+			da.estimate()
+		}
+	}
+	return nil
+}
+
+// record appends a frame pair to the analysis history, keeping the last window samples
+func (da *delayAligner) record(mic, speaker []int16) {
+	da.micHist = appendWindow(da.micHist, mic, da.window)
+	da.spkHist = appendWindow(da.spkHist, speaker, da.window)
+}
+
+// estimate updates the delay from the current history if the correlation peak is reliable
+// Only the first attempt and changes of the delay are reported
+func (da *delayAligner) estimate() {
+	first := !da.attempted
+	da.attempted = true
+
+	lag, confidence := da.estimator.Estimate(da.spkHist, da.micHist)
+	if confidence < minDelayConfidence {
+		if !first {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Delay estimate: no reliable echo path found (confidence %.1f), keeping %s\n",
+			confidence, da.formatDelay(da.stage.delay()))
+		return
+	}
+	if lag != da.stage.delay() || first {
+		if lag < 0 {
+			fmt.Fprintf(os.Stderr, "Delay estimate: mic leads speaker, speaker advanced by %s (confidence %.1f)\n", da.formatDelay(-lag), confidence)
+		} else {
+			fmt.Fprintf(os.Stderr, "Delay estimate: speaker delayed by %s (confidence %.1f)\n", da.formatDelay(lag), confidence)
+		}
+	}
+	da.stage.setDelay(lag)
+}
+
+// formatDelay formats a delay in samples with its duration
+func (da *delayAligner) formatDelay(samples int) string {
+	return fmt.Sprintf("%d samples (%.1fms)", samples, float64(samples)*1000/float64(da.sampleRate))
+}
+
+// appendWindow appends src to dst and drops the oldest samples beyond size
+func appendWindow(dst, src []int16, size int) []int16 {
+	dst = append(dst, src...)
+	if excess := len(dst) - size; excess > 0 {
+		dst = dst[:copy(dst, dst[excess:])]
+	}
+	return dst
+}
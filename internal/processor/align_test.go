@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"open_tool_speex/pkg/types"
+)
+
+// sliceSource serves mic and speaker frames from in-memory signals
+type sliceSource struct {
+	mic, speaker []int16
+	pos          int
+}
+
+func (s *sliceSource) readFrame(mic, speaker []int16) error {
+	if s.pos >= len(s.mic) {
+		return io.EOF
+	}
+	n := copy(mic, s.mic[s.pos:])
+	copy(speaker, s.speaker[s.pos:])
+	clear(mic[n:])
+	clear(speaker[n:])
+	s.pos += n
+	return nil
+}
+
+// echoSignals returns a noise speaker signal and a mic signal carrying its echo delayed by each
+// delays[i] over consecutive segments of segment samples
+func echoSignals(segment int, delays ...int) (mic, speaker []int16) {
+	rng := rand.New(rand.NewSource(7))
+	speaker = make([]int16, segment*len(delays))
+	for i := range speaker {
+		speaker[i] = int16(rng.Intn(16000) - 8000)
+	}
+	mic = make([]int16, len(speaker))
+	for seg, delay := range delays {
+		for i := seg * segment; i < (seg+1)*segment; i++ {
+			if i-delay >= 0 && i-delay < len(speaker) {
+				mic[i] = speaker[i-delay] / 2
+			}
+		}
+	}
+	return mic, speaker
+}
+
+func TestDelayAligner(t *testing.T) {
+	tests := []struct {
+		name      string
+		updateSec float64
+		delays    []int
+		want      []int // delay after each segment
+	}{
+		{name: "estimate once", updateSec: 0, delays: []int{1200, 2400}, want: []int{1200, 1200}},
+		{name: "periodic re-estimation", updateSec: 0.5, delays: []int{1200, 2400}, want: []int{1200, 2400}},
+		{name: "mic leads speaker", updateSec: 0, delays: []int{-320}, want: []int{-320}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{
+				SampleRate:     16000,
+				FrameSize:      320,
+				MaxDelayMs:     250,
+				DelayWindowSec: 1.0,
+				DelayUpdateSec: tt.updateSec,
+			}
+			segment := 3 * config.SampleRate
+			mic, speaker := echoSignals(segment, tt.delays...)
+
+			aligner := NewProcessor(config).newDelayAligner(&sliceSource{mic: mic, speaker: speaker})
+			if err := aligner.prime(config.FrameSize); err != nil {
+				t.Fatalf("prime() error = %v", err)
+			}
+
+			micFrame := make([]int16, config.FrameSize)
+			speakerFrame := make([]int16, config.FrameSize)
+			for seg := range tt.delays {
+				for i := 0; i < segment/config.FrameSize; i++ {
+					if err := aligner.readFrame(micFrame, speakerFrame); err != nil {
+						t.Fatalf("readFrame() error = %v", err)
+					}
+					aligner.stage.process(micFrame, speakerFrame)
+				}
+				if got := aligner.stage.delay(); got != tt.want[seg] {
+					t.Errorf("delay after segment %d = %d, want %d", seg, got, tt.want[seg])
+				}
+			}
+			if err := aligner.readFrame(micFrame, speakerFrame); err != io.EOF {
+				t.Errorf("readFrame() at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDelayAligner_AlignedFrames(t *testing.T) {
+	config := &types.Config{
+		SampleRate:     16000,
+		FrameSize:      320,
+		MaxDelayMs:     100,
+		DelayWindowSec: 0.5,
+	}
+	mic, speaker := echoSignals(config.SampleRate, 500)
+
+	aligner := NewProcessor(config).newDelayAligner(&sliceSource{mic: mic, speaker: speaker})
+	if err := aligner.prime(config.FrameSize); err != nil {
+		t.Fatalf("prime() error = %v", err)
+	}
+
+	// After alignment the mic frame must be exactly half the shifted speaker frame
+	micFrame := make([]int16, config.FrameSize)
+	speakerFrame := make([]int16, config.FrameSize)
+	for frame := 0; ; frame++ {
+		if err := aligner.readFrame(micFrame, speakerFrame); err == io.EOF {
+			break
+		}
+		alignedMic, alignedSpeaker := aligner.stage.process(micFrame, speakerFrame)
+		for i := range alignedMic {
+			if alignedMic[i] != alignedSpeaker[i]/2 {
+				t.Fatalf("frame %d sample %d: mic = %d, aligned speaker/2 = %d", frame, i, alignedMic[i], alignedSpeaker[i]/2)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+
+	"open_tool_speex/internal/dsp"
+	"open_tool_speex/pkg/types"
+)
+
+// comfortNoise tracks the background of the mic signal and fills suppressed output frames
+type comfortNoise struct {
+	generator *dsp.ComfortNoise
+	fill      bool // top up suppressed frames (otherwise only used by the VAD gate)
+	filled    int
+	total     int
+}
+
+// newComfortNoise creates the generator, or returns nil if neither comfort fill
+// nor the noise gate is enabled
+func (p *Processor) newComfortNoise() *comfortNoise {
+	if !p.config.ComfortFill && p.config.VADGate != types.GateNoise {
+		return nil
+	}
+	// The background is estimated from the mic input; the noise stays at the level
+	// the ns stage leaves it so the fill does not undo the noise suppression
+	offsetDB := p.config.ComfortNoiseOffsetDB
+	if p.config.HasStage(types.StageNS) && p.config.NS.EnableDenoise {
+		offsetDB += p.config.NS.NoiseSuppress
+	}
+	return &comfortNoise{
+		generator: dsp.NewComfortNoise(p.config.FrameSize, offsetDB, 1),
+		fill:      p.config.ComfortFill,
+	}
+}
+
+// process updates the background estimate from the mic frame and fills the output frame in place
+func (cn *comfortNoise) process(mic, output []int16) {
+	cn.generator.Update(mic)
+	if !cn.fill {
+		return
+	}
+	cn.total++
+	if cn.generator.Fill(output) {
+		cn.filled++
+	}
+}
+
+// report prints how many frames received comfort noise
+func (cn *comfortNoise) report() {
+	if !cn.fill {
+		return
+	}
+	percent := 0.0
+	if cn.total > 0 {
+		percent = float64(cn.filled) * 100 / float64(cn.total)
+	}
+	fmt.Fprintf(os.Stderr, "Comfort noise: %d of %d frames filled (%.1f%%)\n", cn.filled, cn.total, percent)
+}
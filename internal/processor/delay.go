@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"open_tool_speex/internal/dsp"
+)
+
+// delayStage shifts the speaker stream relative to the mic by a signed number of samples
+// A positive delay delays the speaker; a negative one advances it by delaying the mic,
+// which delays the output by the same amount
+type delayStage struct {
+	mic     *dsp.DelayLine
+	speaker *dsp.DelayLine
+	micOut  []int16
+	spkOut  []int16
+}
+
+// newDelayStage creates a delay stage supporting shifts up to maxDelay samples in either direction
+func newDelayStage(maxDelay, frameSize int) *delayStage {
+	return &delayStage{
+		mic:     dsp.NewDelayLine(maxDelay),
+		speaker: dsp.NewDelayLine(maxDelay),
+		micOut:  make([]int16, frameSize),
+		spkOut:  make([]int16, frameSize),
+	}
+}
+
+// setDelay changes the speaker delay in samples (negative = advance)
+func (ds *delayStage) setDelay(delay int) {
+	ds.speaker.SetDelay(max(delay, 0))
+	ds.mic.SetDelay(max(-delay, 0))
+}
+
+// delay returns the current speaker delay in samples (negative = advance)
+func (ds *delayStage) delay() int {
+	return ds.speaker.Delay() - ds.mic.Delay()
+}
+
+// process returns the mic and speaker frames shifted by the current delay
+// The returned slices are reused by the next call
+func (ds *delayStage) process(mic, speaker []int16) ([]int16, []int16) {
+	ds.mic.Process(ds.micOut, mic)
+	ds.speaker.Process(ds.spkOut, speaker)
+	return ds.micOut, ds.spkOut
+}
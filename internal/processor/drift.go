@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"open_tool_speex/internal/dsp"
+	"open_tool_speex/pkg/speex"
+)
+
+// driftRatioScale is the denominator of the fractional resampling ratio (1 ppm resolution)
+const driftRatioScale = 1000000
+
+// driftCompensator measures clock drift between mic and speaker from the trend of their
+// delay and continuously resamples the speaker stream to cancel it
+// Frames are read ahead from the source as needed, so the mic stream is delayed by at most
+// the accumulated drift
+type driftCompensator struct {
+	source     frameSource
+	resampler  *speex.Resampler
+	estimator  *dsp.DelayEstimator
+	tracker    dsp.DriftTracker
+	sampleRate int
+	window     int // analysis window in samples
+	interval   int // measurement interval in samples
+	frameSize  int
+	micQueue   []int16 // mic samples read ahead
+	spkQueue   []int16 // speaker samples awaiting resampling
+	micHist    []int16
+	spkHist    []int16
+	micFrame   []int16
+	spkFrame   []int16
+	read       int // samples per channel read from the source
+	sinceLast  int
+	ppm        float64 // applied correction
+	eof        bool
+}
+
+// newDriftCompensator creates a drift compensator reading frames from source
+func (p *Processor) newDriftCompensator(source frameSource) (*driftCompensator, error) {
+	resampler, err := speex.NewResampler(1, p.config.SampleRate, p.config.SampleRate, p.config.ResampleQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize drift resampler: %w", err)
+	}
+	resampler.SkipZeros()
+
+	maxDelay := p.config.SampleRate * p.config.MaxDelayMs / 1000
+	window := int(p.config.DelayWindowSec * float64(p.config.SampleRate))
+	return &driftCompensator{
+		source:     source,
+		resampler:  resampler,
+		estimator:  dsp.NewDelayEstimator(window, maxDelay),
+		sampleRate: p.config.SampleRate,
+		window:     window,
+		interval:   int(p.config.DriftUpdateSec * float64(p.config.SampleRate)),
+		frameSize:  p.config.FrameSize,
+		micHist:    make([]int16, 0, window),
+		spkHist:    make([]int16, 0, window),
+		micFrame:   make([]int16, p.config.FrameSize),
+		spkFrame:   make([]int16, p.config.FrameSize),
+	}, nil
+}
+
+// readFrame returns the next mic frame and the drift-corrected speaker frame
+func (dc *driftCompensator) readFrame(mic, speaker []int16) error {
+	for len(dc.micQueue) < len(mic) {
+		if err := dc.fill(); err != nil {
+			if errors.Is(err, io.EOF) && len(dc.micQueue) > 0 {
+				break
+			}
+			return err
+		}
+	}
+	n := copy(mic, dc.micQueue)
+	clear(mic[n:])
+	dc.micQueue = dc.micQueue[:copy(dc.micQueue, dc.micQueue[n:])]
+
+	produced := 0
+	for produced < len(speaker) {
+		consumed, out, err := dc.resampler.Process(dc.spkQueue, speaker[produced:])
+		if err != nil {
+			return err
+		}
+		dc.spkQueue = dc.spkQueue[:copy(dc.spkQueue, dc.spkQueue[consumed:])]
+		produced += out
+		if produced == len(speaker) {
+			break
+		}
+		if err := dc.fill(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			// Reference exhausted: the rest of the frame is silent
+			clear(speaker[produced:])
+			break
+		}
+	}
+	return nil
+}
+
+// fill reads one frame pair from the source into the queues and updates the drift estimate
+func (dc *driftCompensator) fill() error {
+	if dc.eof {
+		return io.EOF
+	}
+	err := dc.source.readFrame(dc.micFrame, dc.spkFrame)
+	if errors.Is(err, io.EOF) {
+		dc.eof = true
+	}
+	if err != nil {
+		return err
+	}
+
+	dc.micQueue = append(dc.micQueue, dc.micFrame...)
+	dc.spkQueue = append(dc.spkQueue, dc.spkFrame...)
+	dc.micHist = appendWindow(dc.micHist, dc.micFrame, dc.window)
+	dc.spkHist = appendWindow(dc.spkHist, dc.spkFrame, dc.window)
+	dc.read += len(dc.micFrame)
+	dc.sinceLast += len(dc.micFrame)
+
+	if dc.sinceLast >= dc.interval && len(dc.micHist) == dc.window {
+		dc.sinceLast = 0
+		return dc.update()
+	}
+	return nil
+}
+
+// update adds a delay measurement of the raw streams and adjusts the resampling ratio
+func (dc *driftCompensator) update() error {
+	lag, confidence := dc.estimator.Estimate(dc.spkHist, dc.micHist)
+	if confidence < minDelayConfidence {
+		return nil
+	}
+	// Time the measurement at the centre of the analysis window
+	dc.tracker.Add(dc.read-dc.window/2, lag)
+
+	ppm, ok := dc.tracker.PPM()
+	if !ok || math.Round(ppm) == math.Round(dc.ppm) {
+		return nil
+	}
+
+	// A growing delay means the speaker must be stretched: input/output = 1/(1+drift)
+	ratioDen := int(math.Round(driftRatioScale + ppm))
+	if ratioDen <= 0 {
+		return nil
+	}
+	if err := dc.resampler.SetRateFrac(driftRatioScale, ratioDen, dc.sampleRate, dc.sampleRate); err != nil {
+		return err
+	}
+	dc.ppm = ppm
+	return nil
+}
+
+// report prints the measured drift
+func (dc *driftCompensator) report() {
+	ppm, ok := dc.tracker.PPM()
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Clock drift: not measured (%d reliable delay estimates)\n", dc.tracker.Points())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Clock drift: %+.1f ppm (%d delay estimates, speaker resampled at %+.0f ppm)\n",
+		ppm, dc.tracker.Points(), math.Round(dc.ppm))
+}
+
+// Destroy cleans up resources
+func (dc *driftCompensator) Destroy() {
+	dc.resampler.Destroy()
+}
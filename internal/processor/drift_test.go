@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"open_tool_speex/internal/dsp"
+	"open_tool_speex/pkg/types"
+)
+
+// driftSignals returns a noise speaker signal and a mic signal carrying its echo delayed by
+// delay samples, with the mic clock running ppm faster than the speaker clock
+func driftSignals(n, delay int, ppm float64) (mic, speaker []int16) {
+	rng := rand.New(rand.NewSource(11))
+	speaker = make([]int16, n)
+	for i := range speaker {
+		speaker[i] = int16(rng.Intn(16000) - 8000)
+	}
+	mic = make([]int16, n)
+	for i := range mic {
+		if j := int(math.Round(float64(i)/(1+ppm/1e6))) - delay; j >= 0 && j < n {
+			mic[i] = speaker[j] / 2
+		}
+	}
+	return mic, speaker
+}
+
+func TestDriftCompensator(t *testing.T) {
+	tests := []struct {
+		name string
+		ppm  float64
+	}{
+		{name: "mic clock faster", ppm: 400},
+		{name: "mic clock slower", ppm: -250},
+		{name: "no drift", ppm: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{
+				SampleRate:        8000,
+				FrameSize:         160,
+				ResampleQuality:   4,
+				MaxDelayMs:        100,
+				DelayWindowSec:    1.0,
+				DriftCompensation: true,
+				DriftUpdateSec:    1.0,
+			}
+			mic, speaker := driftSignals(20*config.SampleRate, 200, tt.ppm)
+
+			drift, err := NewProcessor(config).newDriftCompensator(&sliceSource{mic: mic, speaker: speaker})
+			if err != nil {
+				t.Fatalf("newDriftCompensator() error = %v", err)
+			}
+			defer drift.Destroy()
+
+			// Collect the compensated streams
+			var outMic, outSpeaker []int16
+			micFrame := make([]int16, config.FrameSize)
+			speakerFrame := make([]int16, config.FrameSize)
+			for {
+				err := drift.readFrame(micFrame, speakerFrame)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("readFrame() error = %v", err)
+				}
+				outMic = append(outMic, micFrame...)
+				outSpeaker = append(outSpeaker, speakerFrame...)
+			}
+			if len(outMic) != len(mic) {
+				t.Errorf("compensated mic length = %d, want %d", len(outMic), len(mic))
+			}
+			for i := range mic {
+				if outMic[i] != mic[i] {
+					t.Fatalf("compensated mic sample %d = %d, want %d (mic must pass unchanged)", i, outMic[i], mic[i])
+				}
+			}
+
+			ppm, ok := drift.tracker.PPM()
+			if !ok {
+				t.Fatalf("PPM() not available after %d seconds", len(mic)/config.SampleRate)
+			}
+			if math.Abs(ppm-tt.ppm) > 40 {
+				t.Errorf("PPM() = %.1f, want %.1f", ppm, tt.ppm)
+			}
+
+			// Once compensation is active the delay of the corrected reference stays put
+			second := config.SampleRate
+			estimator := dsp.NewDelayEstimator(2*second, 800)
+			early, _ := estimator.Estimate(outSpeaker[5*second:7*second], outMic[5*second:7*second])
+			late, _ := estimator.Estimate(outSpeaker[17*second:19*second], outMic[17*second:19*second])
+			if abs(late-early) > 3 {
+				t.Errorf("compensated delay moved from %d to %d samples", early, late)
+			}
+		})
+	}
+}
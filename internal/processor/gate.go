@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+
+	"open_tool_speex/internal/dsp"
+	"open_tool_speex/internal/vad"
+	"open_tool_speex/pkg/types"
+)
+
+// outputGate replaces or drops output frames without speech
+type outputGate struct {
+	mode      types.VADGate
+	noise     []int16
+	generator *dsp.ComfortNoise // comfort noise source of GateNoise
+	noiseDB   float64           // comfort noise level in dBFS
+	edits     *vad.EditList
+	editPath  string
+	frameSize int
+	rate      int
+	gated     int // frames without speech
+	total     int
+}
+
+// newOutputGate creates the gate, or returns nil if the output is not gated
+// comfort provides the noise of GateNoise
+func (p *Processor) newOutputGate(comfort *comfortNoise) *outputGate {
+	if p.config.VADGate == types.GateOff {
+		return nil
+	}
+	gate := &outputGate{
+		mode:      p.config.VADGate,
+		noise:     make([]int16, p.config.FrameSize),
+		edits:     vad.NewEditList(p.config.SampleRate, p.config.FrameSize),
+		noiseDB:   p.config.ComfortNoiseDB,
+		editPath:  p.config.VADEditList,
+		frameSize: p.config.FrameSize,
+		rate:      p.config.SampleRate,
+	}
+	if comfort != nil {
+		gate.generator = comfort.generator
+	}
+	return gate
+}
+
+// apply returns the frame to write in place of frame and whether to write it at all
+func (g *outputGate) apply(frame []int16, speech bool) ([]int16, bool) {
+	g.total++
+	if g.mode == types.GateDrop {
+		g.edits.Add(speech)
+	}
+	if speech {
+		return frame, true
+	}
+	g.gated++
+
+	switch g.mode {
+	case types.GateSilence:
+		// PCM zero encodes to A-law 0xD5 / mu-law 0xFF
+		clear(g.noise)
+		return g.noise[:len(frame)], true
+	case types.GateNoise:
+		g.generator.GenerateLevel(g.noise, g.noiseDB)
+		return g.noise[:len(frame)], true
+	default:
+		return nil, false
+	}
+}
+
+// finish reports the gated audio and writes the edit list
+func (g *outputGate) finish() error {
+	seconds := func(frames int) float64 {
+		return float64(frames*g.frameSize) / float64(g.rate)
+	}
+	percent := 0.0
+	if g.total > 0 {
+		percent = float64(g.gated) * 100 / float64(g.total)
+	}
+
+	action := map[types.VADGate]string{
+		types.GateSilence: "replaced with silence",
+		types.GateNoise:   "replaced with comfort noise",
+		types.GateDrop:    "dropped",
+	}[g.mode]
+	fmt.Fprintf(os.Stderr, "VAD gate: %.1f of %.1f seconds without speech %s (%.1f%%)\n",
+		seconds(g.gated), seconds(g.total), action, percent)
+
+	if g.editPath == "" {
+		return nil
+	}
+	if err := writeFile(g.editPath, g.edits.WriteCSV); err != nil {
+		return fmt.Errorf("failed to write edit list: %w", err)
+	}
+	return nil
+}
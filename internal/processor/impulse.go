@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"open_tool_speex/internal/metrics"
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/speex"
+)
+
+// tailWarning is the tail energy share above which -echo-tail is reported as too short
+const tailWarning = 0.1
+
+// impulseDumper writes snapshots of the echo path learned by the echo canceller
+// Periodic snapshots go to numbered files next to the final one (ir.csv -> ir_0001.csv, ...)
+type impulseDumper struct {
+	aec       *speex.AEC
+	path      string
+	interval  int // frames between snapshots (0 = final snapshot only)
+	snapshots int
+	rate      int
+}
+
+// newImpulseDumper creates the impulse response output, or returns nil if it is disabled
+// or the mode does not use the echo canceller
+func (p *Processor) newImpulseDumper(aec *speex.AEC) *impulseDumper {
+	if p.config.ImpulseResponseFile == "" || aec == nil {
+		return nil
+	}
+	interval := 0
+	if p.config.ImpulseResponseSec > 0 {
+		interval = max(1, int(p.config.ImpulseResponseSec*float64(p.config.SampleRate)/float64(p.config.FrameSize)+0.5))
+	}
+	return &impulseDumper{
+		aec:      aec,
+		path:     p.config.ImpulseResponseFile,
+		interval: interval,
+		rate:     p.config.SampleRate,
+	}
+}
+
+// add writes a periodic snapshot when one is due after the given number of frames
+func (d *impulseDumper) add(frameCount int) error {
+	if d.interval == 0 || frameCount%d.interval != 0 {
+		return nil
+	}
+	d.snapshots++
+	ext := filepath.Ext(d.path)
+	path := fmt.Sprintf("%s_%04d%s", strings.TrimSuffix(d.path, ext), d.snapshots, ext)
+	_, err := d.write(path)
+	return err
+}
+
+// finish writes the final snapshot and reports the echo path peak and tail energy
+func (d *impulseDumper) finish() error {
+	ir, err := d.write(d.path)
+	if err != nil {
+		return err
+	}
+	peak := ir.Peak()
+	if peak < 0 {
+		return nil
+	}
+	tail := ir.TailEnergy()
+	fmt.Fprintf(os.Stderr, "Echo path: peak at %.1fms (tap %d of %d), %.1f%% of the energy in the last %.0f%% of the filter\n",
+		ir.TapMs(peak), peak, len(ir.Taps), tail*100, metrics.TailFraction*100)
+	if tail > tailWarning {
+		fmt.Fprintf(os.Stderr, "Echo path: the echo outlasts the filter, consider a longer -echo-tail\n")
+	}
+	return nil
+}
+
+// write writes the current impulse response to path (WAV if the name ends in .wav, CSV otherwise)
+func (d *impulseDumper) write(path string) (*metrics.ImpulseResponse, error) {
+	ir := metrics.NewImpulseResponse(d.rate, d.aec.ImpulseResponse())
+	write := ir.WriteCSV
+	if audio.HasWAVExtension(path) {
+		write = ir.WriteWAV
+	}
+	if err := writeFile(path, write); err != nil {
+		return nil, fmt.Errorf("failed to write impulse response: %w", err)
+	}
+	return ir, nil
+}
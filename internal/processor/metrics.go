@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+
+	"open_tool_speex/internal/metrics"
+)
+
+// echoMetrics collects echo cancellation metrics and writes the JSON summary and CSV files
+type echoMetrics struct {
+	collector *metrics.Collector
+	csvFile   *os.File
+	jsonPath  string
+}
+
+// newEchoMetrics creates the metrics outputs, or returns nil if metrics are disabled
+// or the mode does not use the echo canceller
+func (p *Processor) newEchoMetrics() (*echoMetrics, error) {
+	if p.config.MetricsFile == "" && p.config.MetricsCSV == "" {
+		return nil, nil
+	}
+	if !p.needsSpeakerFile() {
+		return nil, nil
+	}
+
+	em := &echoMetrics{jsonPath: p.config.MetricsFile}
+	if p.config.MetricsCSV != "" {
+		file, err := os.Create(p.config.MetricsCSV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metrics CSV file: %w", err)
+		}
+		em.csvFile = file
+		em.collector = metrics.NewCollector(p.config.SampleRate, p.config.FrameSize, file)
+	} else {
+		em.collector = metrics.NewCollector(p.config.SampleRate, p.config.FrameSize, nil)
+	}
+	return em, nil
+}
+
+// add records the frames fed to the echo canceller and the processed output
+func (em *echoMetrics) add(mic, speaker, output []int16) error {
+	_, err := em.collector.Add(mic, speaker, output)
+	return err
+}
+
+// finish writes the JSON summary and prints the headline figures
+func (em *echoMetrics) finish() error {
+	if err := em.collector.Flush(); err != nil {
+		return fmt.Errorf("failed to write metrics CSV: %w", err)
+	}
+
+	summary := em.collector.Summary()
+	if summary.ERLE != nil {
+		fmt.Fprintf(os.Stderr, "ERLE: %.1f dB, residual echo %.1f dBFS (%d far-end-only frames)\n",
+			*summary.ERLE, *summary.ResidualEchoDB, summary.ERLEFrames)
+	} else {
+		fmt.Fprintf(os.Stderr, "ERLE: not measured (no far-end-only frames)\n")
+	}
+
+	if em.jsonPath == "" {
+		return nil
+	}
+	file, err := os.Create(em.jsonPath)
+	if err != nil {
+		return fmt.Errorf("failed to create metrics file: %w", err)
+	}
+	defer file.Close()
+	if err := em.collector.WriteJSON(file); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return file.Close()
+}
+
+// Close closes the CSV file
+func (em *echoMetrics) Close() error {
+	if em.csvFile != nil {
+		return em.csvFile.Close()
+	}
+	return nil
+}
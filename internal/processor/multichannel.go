@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"open_tool_speex/pkg/speex"
+	"open_tool_speex/pkg/types"
+)
+
+// processMultiChannel runs the multi-channel AEC loop: N mic and M speaker channels in,
+// N cleaned mic channels out
+func (p *Processor) processMultiChannel(ctx context.Context, inputs *audioInputs, out *audioWriter) error {
+	micChannels := p.config.MicChannels()
+	speakerChannels := p.config.SpeakerChannels()
+
+	aec, err := speex.NewMultiAEC(p.config.FrameSize, p.config.FilterLen, p.config.SampleRate, micChannels, speakerChannels, p.config.NS)
+	if err != nil {
+		return fmt.Errorf("failed to initialize multi-channel AEC: %w", err)
+	}
+	defer aec.Destroy()
+
+	micFrames := makeFrames(micChannels, p.config.FrameSize)
+	speakerFrames := makeFrames(speakerChannels, p.config.FrameSize)
+	micPcm := make([]int16, micChannels*p.config.FrameSize)
+	speakerPcm := make([]int16, speakerChannels*p.config.FrameSize)
+	outputPcm := make([]int16, micChannels*p.config.FrameSize)
+
+	frameCount := 0
+	p.printModeInfo()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := inputs.readChannels(micFrames, speakerFrames)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		interleave(micPcm, micFrames)
+		interleave(speakerPcm, speakerFrames)
+
+		if !p.config.HasStage(types.StageNS) {
+			err = aec.ProcessFrameEchoOnlyInto(outputPcm, micPcm, speakerPcm)
+		} else {
+			err = aec.ProcessFrameInto(outputPcm, micPcm, speakerPcm)
+		}
+		if err != nil {
+			return fmt.Errorf("error processing frame %d: AEC processing failed: %w", frameCount, err)
+		}
+
+		if err := out.writeInterleaved(outputPcm); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+
+		frameCount++
+		p.logProgress(frameCount)
+	}
+
+	duration := float64(frameCount*p.config.FrameSize) / float64(p.config.SampleRate)
+	fmt.Fprintf(os.Stderr, "Total processed: %.1f seconds (%d frames, %d channels)\n", duration, frameCount, micChannels)
+	return nil
+}
+
+// makeFrames allocates one frame per channel
+func makeFrames(channels, frameSize int) [][]int16 {
+	frames := make([][]int16, channels)
+	for i := range frames {
+		frames[i] = make([]int16, frameSize)
+	}
+	return frames
+}
+
+// interleave writes per-channel frames into one interleaved frame
+func interleave(dst []int16, frames [][]int16) {
+	channels := len(frames)
+	for ch, frame := range frames {
+		for i, sample := range frame {
+			dst[i*channels+ch] = sample
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package processor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
-	"open_tool_speex/internal/audio"
-	"open_tool_speex/internal/speex"
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/speex"
+	"open_tool_speex/pkg/stage"
 	"open_tool_speex/pkg/types"
 )
 
@@ -24,120 +28,154 @@ func NewProcessor(config *types.Config) *Processor {
 
 // Process performs audio processing based on the configuration
 func (p *Processor) Process() error {
+	return p.ProcessContext(context.Background())
+}
+
+// ProcessContext processes the files named in the configuration until done or ctx is cancelled
+func (p *Processor) ProcessContext(ctx context.Context) error {
 	// Open input files
-	micFile, err := os.Open(p.config.MicFile)
+	inputs, err := p.openInputs()
 	if err != nil {
-		return fmt.Errorf("failed to open mic file: %w", err)
+		return err
 	}
-	defer micFile.Close()
+	defer inputs.Close()
 
-	var speakerFile *os.File
-	if p.needsSpeakerFile() {
-		speakerFile, err = os.Open(p.config.SpeakerFile)
+	// Create output file (standard output is written but not closed)
+	outFile := os.Stdout
+	if p.config.OutputFile != types.StdioPath {
+		outFile, err = os.Create(p.config.OutputFile)
 		if err != nil {
-			return fmt.Errorf("failed to open speaker file: %w", err)
+			return fmt.Errorf("failed to create output file: %w", err)
 		}
-		defer speakerFile.Close()
+		defer outFile.Close()
 	}
 
-	// Create output file
-	outFile, err := os.Create(p.config.OutputFile)
+	return p.process(ctx, inputs, outFile, audio.HasWAVExtension(p.config.OutputFile))
+}
+
+// ProcessStreams processes caller-provided streams in place of the configured files
+// until done or ctx is cancelled; the streams are not closed
+func (p *Processor) ProcessStreams(ctx context.Context, streams types.Streams) error {
+	inputs, err := p.streamInputs(streams)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
-	defer outFile.Close()
+	defer inputs.Close()
 
-	// Initialize components based on mode
-	var aec *speex.AEC
-	var separateNS *speex.Preprocessor
+	return p.process(ctx, inputs, streams.Output, streams.OutputWAV)
+}
 
-	if err := p.initializeComponents(&aec, &separateNS); err != nil {
-		return err
+// process runs the processing of opened inputs into the output (a WAV stream if wav is set)
+// Cancellation of ctx is checked between frames
+func (p *Processor) process(ctx context.Context, inputs *audioInputs, w io.Writer, wav bool) error {
+	outChannels := 1
+	if p.config.StereoOutput {
+		outChannels = 2
 	}
-
-	// Cleanup components
-	if aec != nil {
-		defer aec.Destroy()
+	if p.config.MultiChannel() {
+		outChannels = p.config.MicChannels()
 	}
-	if separateNS != nil {
-		defer separateNS.Destroy()
+	out, err := p.newAudioWriter(w, wav, outChannels)
+	if err != nil {
+		return fmt.Errorf("failed to initialize output: %w", err)
 	}
+	defer out.destroy()
 
-	// Process audio
-	return p.processAudio(micFile, speakerFile, outFile, aec, separateNS)
-}
-
-// needsSpeakerFile returns true if speaker file is needed for current mode
-func (p *Processor) needsSpeakerFile() bool {
-	return p.config.Mode != types.ModeNSOnly &&
-		p.config.Mode != types.ModeBypass &&
-		p.config.Mode != types.ModeTestAlaw
-}
-
-// initializeComponents initializes AEC and preprocessor based on mode
-func (p *Processor) initializeComponents(aec **speex.AEC, separateNS **speex.Preprocessor) error {
-	switch p.config.Mode {
-	case types.ModeBypass, types.ModeTestAlaw:
-		// No processing needed
+	// Multi-channel AEC has its own processing loop
+	if p.config.MultiChannel() {
+		if err := p.processMultiChannel(ctx, inputs, out); err != nil {
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to finalize output: %w", err)
+		}
 		return nil
+	}
 
-	case types.ModeNSOnly:
-		// Only need standalone preprocessor
-		ns, err := speex.NewPreprocessorWithConfig(p.config.FrameSize, p.config.SampleRate, p.config.NS)
-		if err != nil {
-			return fmt.Errorf("failed to initialize NS: %w", err)
-		}
-		*separateNS = ns
+	// Processing chain of the configured stages (or the preset of the mode)
+	chain, err := stage.NewChain(p.config.Stages(), p.config)
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
 
-	case types.ModeAECOnly:
-		// Only need AEC
-		aecInstance, err := speex.NewAEC(p.config.FrameSize, p.config.FilterLen, p.config.SampleRate)
-		if err != nil {
-			return fmt.Errorf("failed to initialize AEC: %w", err)
-		}
-		*aec = aecInstance
+	// Echo metrics (nil if disabled)
+	stats, err := p.newEchoMetrics()
+	if err != nil {
+		return err
+	}
+	if stats != nil {
+		defer stats.Close()
+	}
 
-	case types.ModeNSFirst:
-		// Need both AEC and separate preprocessor
-		aecInstance, err := speex.NewAEC(p.config.FrameSize, p.config.FilterLen, p.config.SampleRate)
-		if err != nil {
-			return fmt.Errorf("failed to initialize AEC: %w", err)
-		}
-		*aec = aecInstance
+	// VAD on the output of chains without an ns stage of their own (nil otherwise)
+	detector, err := p.newVADDetector()
+	if err != nil {
+		return err
+	}
+	if detector != nil {
+		defer detector.Destroy()
+	}
 
-		ns, err := speex.NewPreprocessorWithConfig(p.config.FrameSize, p.config.SampleRate, p.config.NS)
-		if err != nil {
-			return fmt.Errorf("failed to initialize separate NS: %w", err)
-		}
-		*separateNS = ns
+	// Voice activity timeline, speech segment files and output gate (nil if disabled)
+	timeline := p.newVADTimeline()
+	comfort := p.newComfortNoise()
+	gate := p.newOutputGate(comfort)
+	segments, err := p.newSegmentWriter(outChannels)
+	if err != nil {
+		return err
+	}
+	if segments != nil {
+		defer segments.Close()
+	}
 
-	case types.ModeAECFirst:
-		// Default mode: AEC with built-in preprocessor
-		aecInstance, err := speex.NewAEC(p.config.FrameSize, p.config.FilterLen, p.config.SampleRate)
-		if err != nil {
-			return fmt.Errorf("failed to initialize AEC: %w", err)
-		}
-		*aec = aecInstance
+	// Snapshots of the learned echo path (nil if disabled)
+	impulse := p.newImpulseDumper(chain.Canceller())
 
-	default:
-		return fmt.Errorf("unknown processing mode: %v", p.config.Mode)
+	// Process audio
+	if err := p.processAudio(ctx, inputs, out, chain, detector, stats, timeline, segments, comfort, gate, impulse); err != nil {
+		return err
 	}
 
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to finalize output: %w", err)
+	}
 	return nil
 }
 
+// needsSpeakerFile returns true if the processing chain cancels echo
+func (p *Processor) needsSpeakerFile() bool {
+	return p.config.HasStage(types.StageAEC)
+}
+
+// newVADDetector creates a VAD-only preprocessor for the output of a chain with echo cancellation
+// but no ns stage if an output needs voice activity, or returns nil
+func (p *Processor) newVADDetector() (*speex.Preprocessor, error) {
+	if !p.needsSpeakerFile() || p.config.HasStage(types.StageNS) || !p.config.NeedsVAD() {
+		return nil, nil
+	}
+	ns := p.config.NS
+	ns.EnableDenoise = false
+	ns.EnableAGC = false
+	ns.EnableDereverb = false
+	ns.EnableVAD = true
+	detector, err := speex.NewPreprocessorWithConfig(p.config.FrameSize, p.config.SampleRate, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize VAD: %w", err)
+	}
+	return detector, nil
+}
+
 // processAudio performs the main audio processing loop
-func (p *Processor) processAudio(micFile, speakerFile, outFile *os.File, aec *speex.AEC, separateNS *speex.Preprocessor) error {
+func (p *Processor) processAudio(ctx context.Context, inputs *audioInputs, out *audioWriter, chain *stage.Chain, detector *speex.Preprocessor,
+	stats *echoMetrics, timeline *vadTimeline, segments *segmentWriter, comfort *comfortNoise, gate *outputGate, impulse *impulseDumper) error {
 	// Processing buffers
-	micAlawFrame := make([]byte, p.config.FrameSize)
-	speakerAlawFrame := make([]byte, p.config.FrameSize)
 	micPcmFrame := make([]int16, p.config.FrameSize)
 	speakerPcmFrame := make([]int16, p.config.FrameSize)
-
-	// Previous speaker frame for delay compensation
-	var prevSpeakerPcmFrame []int16
-	if p.config.UsePrevSpeaker {
-		prevSpeakerPcmFrame = make([]int16, p.config.FrameSize)
+	var frame stage.Frame
+	var detectorPcmFrame []int16
+	if detector != nil {
+		detectorPcmFrame = make([]int16, p.config.FrameSize)
 	}
 
 	frameCount := 0
@@ -145,175 +183,221 @@ func (p *Processor) processAudio(micFile, speakerFile, outFile *os.File, aec *sp
 	// Print processing mode info
 	p.printModeInfo()
 
-	// Main processing loop
-	for {
-		// Read mic frame
-		micBytesRead, err := io.ReadFull(micFile, micAlawFrame)
-		if err == io.EOF {
-			break
+	// Speaker delay: estimated automatically (reading the first analysis window
+	// ahead of processing) or fixed by configuration
+	var source frameSource = inputs
+	var drift *driftCompensator
+	var delay *delayStage
+	if p.needsSpeakerFile() {
+		// Drift is measured on the raw streams, ahead of delay alignment
+		if p.config.DriftCompensation {
+			var err error
+			drift, err = p.newDriftCompensator(source)
+			if err != nil {
+				return err
+			}
+			defer drift.Destroy()
+			source = drift
 		}
-		if err != nil && err != io.ErrUnexpectedEOF {
-			return fmt.Errorf("error reading mic file: %w", err)
+
+		if p.config.AutoDelay {
+			aligner := p.newDelayAligner(source)
+			if err := aligner.prime(p.config.FrameSize); err != nil {
+				return err
+			}
+			source = aligner
+			delay = aligner.stage
+		} else if samples := p.config.SpeakerDelaySamples(); samples != 0 {
+			delay = newDelayStage(abs(samples), p.config.FrameSize)
+			delay.setDelay(samples)
 		}
+	}
 
-		// Read speaker frame (only for AEC modes)
-		var speakerBytesRead int
-		if p.needsSpeakerFile() {
-			speakerBytesRead, err = io.ReadFull(speakerFile, speakerAlawFrame)
-			if err == io.EOF {
+	// Mic samples still held back by a negative delay at end of input (-1 while reading)
+	flush := -1
+
+	// Main processing loop
+	for flush != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Read mic and speaker frames (partial frames at end of input are padded with silence)
+		var err error
+		if flush < 0 {
+			err = source.readFrame(micPcmFrame, speakerPcmFrame)
+		}
+		if errors.Is(err, io.EOF) {
+			// Drain the mic delay line against silence so the last mic samples reach the output
+			if delay == nil || delay.delay() >= 0 {
 				break
 			}
-			if err != nil && err != io.ErrUnexpectedEOF {
-				return fmt.Errorf("error reading speaker file: %w", err)
-			}
+			flush = -delay.delay()
+			clear(micPcmFrame)
+			clear(speakerPcmFrame)
+		} else if err != nil {
+			return err
 		}
 
-		// Handle partial frames at end of file
-		p.zeroPadFrames(micAlawFrame, micBytesRead, speakerAlawFrame, speakerBytesRead)
+		// Shift the speaker stream relative to the mic by the echo path delay
+		alignedMicPcmFrame, alignedSpeakerPcmFrame := micPcmFrame, speakerPcmFrame
+		if delay != nil {
+			alignedMicPcmFrame, alignedSpeakerPcmFrame = delay.process(micPcmFrame, speakerPcmFrame)
+		}
 
-		// Convert A-law to PCM16
-		audio.AlawBufferToPCM16(micAlawFrame, micPcmFrame)
+		// Run the frame through the processing chain
+		frame = stage.Frame{Mic: alignedMicPcmFrame}
 		if p.needsSpeakerFile() {
-			audio.AlawBufferToPCM16(speakerAlawFrame, speakerPcmFrame)
+			frame.Speaker = alignedSpeakerPcmFrame
 		}
-
-		// Process frame based on mode
-		outputAlawFrame, err := p.processFrame(micPcmFrame, speakerPcmFrame, prevSpeakerPcmFrame, aec, separateNS)
-		if err != nil {
+		if err := chain.Process(&frame); err != nil {
 			return fmt.Errorf("error processing frame %d: %w", frameCount, err)
 		}
+		outputPcmFrame, vad := frame.Mic, frame.VAD
 
-		// Update previous speaker frame for next iteration
-		if p.config.UsePrevSpeaker && p.needsSpeakerFile() {
-			copy(prevSpeakerPcmFrame, speakerPcmFrame)
+		if detector != nil {
+			if vad, err = detector.ProcessFrameInto(detectorPcmFrame, outputPcmFrame); err != nil {
+				return fmt.Errorf("error processing frame %d: VAD failed: %w", frameCount, err)
+			}
+		}
+		if timeline != nil {
+			timeline.add(vad)
 		}
 
-		// Write output frame
-		if _, err := outFile.Write(outputAlawFrame); err != nil {
-			return fmt.Errorf("error writing output: %w", err)
+		// The last drained frame ends with the delayed mic signal; every consumer below
+		// sees the same truncated frame as the output
+		referencePcmFrame := speakerPcmFrame
+		if flush > 0 {
+			n := min(flush, len(outputPcmFrame))
+			flush -= n
+			outputPcmFrame, referencePcmFrame = outputPcmFrame[:n], referencePcmFrame[:n]
+			alignedMicPcmFrame, alignedSpeakerPcmFrame = alignedMicPcmFrame[:n], alignedSpeakerPcmFrame[:n]
 		}
 
-		frameCount++
-		p.logProgress(frameCount)
-	}
+		if stats != nil {
+			if err := stats.add(alignedMicPcmFrame, alignedSpeakerPcmFrame, outputPcmFrame); err != nil {
+				return fmt.Errorf("error writing metrics: %w", err)
+			}
+		}
 
-	duration := float64(frameCount*p.config.FrameSize) / float64(p.config.SampleRate)
-	fmt.Printf("Total processed: %.1f seconds (%d frames)\n", duration, frameCount)
+		// Fill suppressed frames with noise matching the mic background
+		if comfort != nil {
+			comfort.process(alignedMicPcmFrame, outputPcmFrame)
+		}
 
-	return nil
-}
+		if segments != nil {
+			if p.config.StereoOutput {
+				err = segments.add(vad, outputPcmFrame, referencePcmFrame)
+			} else {
+				err = segments.add(vad, outputPcmFrame)
+			}
+			if err != nil {
+				return err
+			}
+		}
 
-// processFrame processes a single frame based on the current mode
-func (p *Processor) processFrame(micPcmFrame, speakerPcmFrame, prevSpeakerPcmFrame []int16, aec *speex.AEC, separateNS *speex.Preprocessor) ([]byte, error) {
-	switch p.config.Mode {
-	case types.ModeBypass:
-		// Bypass mode: no processing, copy A-law input directly to output
-		// Note: This should copy from micAlawFrame, but we need to pass it as parameter
-		// For now, convert PCM back to A-law
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(micPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	case types.ModeTestAlaw:
-		// Test A-law mode: A-law -> PCM -> A-law
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(micPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	case types.ModeNSOnly:
-		// NS-only mode: only noise suppression
-		outputPcmFrame := separateNS.ProcessFrame(micPcmFrame)
-		if outputPcmFrame == nil {
-			return nil, fmt.Errorf("NS processing failed")
+		// Replace or drop frames without speech
+		gatedPcmFrame, keep := outputPcmFrame, true
+		if gate != nil {
+			gatedPcmFrame, keep = gate.apply(outputPcmFrame, vad.Speech)
 		}
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(outputPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	case types.ModeAECOnly:
-		// AEC-only mode: only echo cancellation
-		aecSpeakerFrame := p.getAECSpeakerFrame(speakerPcmFrame, prevSpeakerPcmFrame)
-		outputPcmFrame := aec.ProcessFrameEchoOnly(micPcmFrame, aecSpeakerFrame)
-		if outputPcmFrame == nil {
-			return nil, fmt.Errorf("AEC processing failed")
+
+		// Write output frame (processed mic and original reference side by side in stereo mode)
+		if keep {
+			if p.config.StereoOutput {
+				err = out.writeFrame(gatedPcmFrame, referencePcmFrame)
+			} else {
+				err = out.writeFrame(gatedPcmFrame)
+			}
+			if err != nil {
+				return fmt.Errorf("error writing output: %w", err)
+			}
 		}
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(outputPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	case types.ModeNSFirst:
-		// NS-first mode: noise suppression, then echo cancellation
-		nsOutput := separateNS.ProcessFrame(micPcmFrame)
-		if nsOutput == nil {
-			return nil, fmt.Errorf("NS processing failed")
+
+		frameCount++
+		p.logProgress(frameCount)
+
+		if impulse != nil {
+			if err := impulse.add(frameCount); err != nil {
+				return err
+			}
 		}
-		aecSpeakerFrame := p.getAECSpeakerFrame(speakerPcmFrame, prevSpeakerPcmFrame)
-		outputPcmFrame := aec.ProcessFrameEchoOnly(nsOutput, aecSpeakerFrame)
-		if outputPcmFrame == nil {
-			return nil, fmt.Errorf("AEC processing failed")
+	}
+
+	duration := float64(frameCount*p.config.FrameSize) / float64(p.config.SampleRate)
+	fmt.Fprintf(os.Stderr, "Total processed: %.1f seconds (%d frames)\n", duration, frameCount)
+	if drift != nil {
+		drift.report()
+	}
+	if stats != nil {
+		if err := stats.finish(); err != nil {
+			return err
 		}
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(outputPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	case types.ModeAECFirst:
-		// AEC-first mode: echo cancellation, then noise suppression (default)
-		aecSpeakerFrame := p.getAECSpeakerFrame(speakerPcmFrame, prevSpeakerPcmFrame)
-		outputPcmFrame := aec.ProcessFrame(micPcmFrame, aecSpeakerFrame)
-		if outputPcmFrame == nil {
-			return nil, fmt.Errorf("AEC processing failed")
+	}
+	if timeline != nil {
+		if err := timeline.finish(); err != nil {
+			return err
 		}
-		outputAlawFrame := make([]byte, p.config.FrameSize)
-		audio.PCM16BufferToAlaw(outputPcmFrame, outputAlawFrame)
-		return outputAlawFrame, nil
-
-	default:
-		return nil, fmt.Errorf("unknown processing mode: %v", p.config.Mode)
 	}
-}
-
-// getAECSpeakerFrame returns the appropriate speaker frame for AEC
-func (p *Processor) getAECSpeakerFrame(speakerPcmFrame, prevSpeakerPcmFrame []int16) []int16 {
-	if p.config.UsePrevSpeaker {
-		return prevSpeakerPcmFrame
+	if segments != nil {
+		if err := segments.finish(); err != nil {
+			return err
+		}
 	}
-	return speakerPcmFrame
-}
-
-// zeroPadFrames zero-pads partial frames at end of file
-func (p *Processor) zeroPadFrames(micAlawFrame []byte, micBytesRead int, speakerAlawFrame []byte, speakerBytesRead int) {
-	if micBytesRead < p.config.FrameSize {
-		for i := micBytesRead; i < p.config.FrameSize; i++ {
-			micAlawFrame[i] = 0xD5 // A-law silence
+	if comfort != nil {
+		comfort.report()
+	}
+	if gate != nil {
+		if err := gate.finish(); err != nil {
+			return err
 		}
 	}
-	if p.needsSpeakerFile() && speakerBytesRead < p.config.FrameSize {
-		for i := speakerBytesRead; i < p.config.FrameSize; i++ {
-			speakerAlawFrame[i] = 0xD5 // A-law silence
+	if impulse != nil {
+		if err := impulse.finish(); err != nil {
+			return err
 		}
 	}
+
+	return nil
 }
 
 // printModeInfo prints information about the processing mode
 func (p *Processor) printModeInfo() {
 	var modeStr []string
-	modeStr = append(modeStr, p.config.Mode.String())
+	if len(p.config.Chain) > 0 {
+		modeStr = append(modeStr, "chain "+strings.Join(p.config.Chain, ","))
+	} else {
+		modeStr = append(modeStr, p.config.Mode.String())
+	}
+
+	if p.config.MultiChannel() {
+		modeStr = append(modeStr, fmt.Sprintf("%d mics x %d speakers", p.config.MicChannels(), p.config.SpeakerChannels()))
+	}
 
-	if p.config.UsePrevSpeaker && p.needsSpeakerFile() {
-		modeStr = append(modeStr, "delay compensation")
+	if p.needsSpeakerFile() {
+		if p.config.DriftCompensation {
+			modeStr = append(modeStr, "drift compensation")
+		}
+		if p.config.AutoDelay {
+			modeStr = append(modeStr, "auto delay")
+		} else if samples := p.config.SpeakerDelaySamples(); samples > 0 {
+			modeStr = append(modeStr, fmt.Sprintf("speaker delay %d samples", samples))
+		} else if samples < 0 {
+			modeStr = append(modeStr, fmt.Sprintf("speaker advance %d samples", -samples))
+		}
 	}
 
 	if len(modeStr) > 0 {
-		fmt.Printf("Processing audio frames (size: %d samples, %.1fms) with %s...\n",
+		fmt.Fprintf(os.Stderr, "Processing audio frames (size: %d samples, %.1fms) with %s...\n",
 			p.config.FrameSize, float64(p.config.FrameSize)/float64(p.config.SampleRate)*1000,
 			fmt.Sprintf("%v", modeStr))
 	} else {
-		fmt.Printf("Processing audio frames (size: %d samples, %.1fms)...\n",
+		fmt.Fprintf(os.Stderr, "Processing audio frames (size: %d samples, %.1fms)...\n",
 			p.config.FrameSize, float64(p.config.FrameSize)/float64(p.config.SampleRate)*1000)
 	}
 
-	if p.config.Mode == types.ModeTestAlaw {
-		fmt.Printf("A-law test mode: Testing A-law -> PCM -> A-law conversion chain\n")
+	if len(p.config.Chain) == 0 && p.config.Mode == types.ModeTestAlaw {
+		fmt.Fprintf(os.Stderr, "A-law test mode: Testing A-law -> PCM -> A-law conversion chain\n")
 	}
 }
 
@@ -326,7 +410,15 @@ func (p *Processor) logProgress(frameCount int) {
 		}
 		if frameCount%framesPerInterval == 0 {
 			duration := float64(frameCount*p.config.FrameSize) / float64(p.config.SampleRate)
-			fmt.Printf("Processed %.1f seconds (%d frames)\n", duration, frameCount)
+			fmt.Fprintf(os.Stderr, "Processed %.1f seconds (%d frames)\n", duration, frameCount)
 		}
 	}
 }
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
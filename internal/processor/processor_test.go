@@ -1,10 +1,23 @@
 package processor
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"open_tool_speex/internal/metrics"
+	"open_tool_speex/pkg/audio"
 	"open_tool_speex/pkg/types"
 )
 
@@ -58,6 +71,7 @@ func TestProcessor_Process(t *testing.T) {
 				SampleRate:  16000,
 				FrameSize:   320,
 				NS: types.NSConfig{
+					EnableDenoise: true,
 					NoiseSuppress: -15.0,
 					EnableVAD:     false,
 					VADProbStart:  80,
@@ -90,6 +104,1166 @@ func TestProcessor_Process(t *testing.T) {
 	}
 }
 
+func TestProcessor_ProcessWAV(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	outputFile := filepath.Join(tempDir, "output.wav")
+
+	// 1.5 frames of PCM16 so the last frame is zero-padded
+	samples := make([]int16, 480)
+	for i := range samples {
+		samples[i] = int16((i%40 - 20) * 500)
+	}
+	createPCM16WAVFile(t, micFile, 16000, samples)
+
+	config := &types.Config{
+		MicFile:      micFile,
+		OutputFile:   outputFile,
+		Mode:         types.ModeBypass,
+		OutputFormat: types.FormatPCM16,
+		SampleRate:   16000,
+		FrameSize:    320,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	file, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	br := bufio.NewReader(file)
+	header, err := audio.ReadWAVHeader(br)
+	if err != nil {
+		t.Fatalf("ReadWAVHeader() error = %v", err)
+	}
+	if header.AudioFormat != audio.WAVFormatPCM || header.SampleRate != 16000 || header.NumChannels != 1 {
+		t.Errorf("Output header = %+v", header)
+	}
+	if header.DataSize != 640*2 {
+		t.Fatalf("Output DataSize = %d, want %d", header.DataSize, 640*2)
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	for i, want := range samples {
+		if got := int16(binary.LittleEndian.Uint16(data[2*i:])); got != want {
+			t.Fatalf("Output sample %d = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestProcessor_ProcessLinearFormats(t *testing.T) {
+	samples := []int16{0, 1, -1, 12345, -12345, 32767, -32768}
+
+	encode := func(format types.SampleFormat) []byte {
+		codec, err := audio.LookupCodec(string(format))
+		if err != nil {
+			t.Fatalf("LookupCodec() error = %v", err)
+		}
+		data := make([]byte, len(samples)*codec.BytesPerSample())
+		codec.Encode(samples, data)
+		return data
+	}
+
+	formats := []types.SampleFormat{types.FormatPCM16, types.FormatS16BE, types.FormatF32LE}
+	for _, in := range formats {
+		for _, out := range formats {
+			t.Run(in.String()+"->"+out.String(), func(t *testing.T) {
+				tempDir := t.TempDir()
+				micFile := filepath.Join(tempDir, "mic.raw")
+				outputFile := filepath.Join(tempDir, "output.raw")
+				if err := os.WriteFile(micFile, encode(in), 0644); err != nil {
+					t.Fatalf("Failed to write mic file: %v", err)
+				}
+
+				config := &types.Config{
+					MicFile:      micFile,
+					OutputFile:   outputFile,
+					Mode:         types.ModeBypass,
+					MicFormat:    in,
+					OutputFormat: out,
+					SampleRate:   16000,
+					FrameSize:    len(samples),
+				}
+				if err := NewProcessor(config).Process(); err != nil {
+					t.Fatalf("Processor.Process() error = %v", err)
+				}
+
+				got, err := os.ReadFile(outputFile)
+				if err != nil {
+					t.Fatalf("Failed to read output: %v", err)
+				}
+				if !bytes.Equal(got, encode(out)) {
+					t.Errorf("Output is not lossless: got %v, want %v", got, encode(out))
+				}
+			})
+		}
+	}
+}
+
+func TestProcessor_ProcessInterleaved(t *testing.T) {
+	// The WAV header channel count overrides -input-channels
+	for _, inputChannels := range []int{2, 1} {
+		t.Run(fmt.Sprintf("input-channels %d", inputChannels), func(t *testing.T) {
+			tempDir := t.TempDir()
+			inputFile := filepath.Join(tempDir, "stereo.wav")
+			outputFile := filepath.Join(tempDir, "output.raw")
+
+			// Reference on the left, mic on the right
+			const frames = 400
+			interleaved := make([]int16, 2*frames)
+			for i := 0; i < frames; i++ {
+				interleaved[2*i] = int16(-i)
+				interleaved[2*i+1] = int16(i)
+			}
+			createPCM16WAVFileChannels(t, inputFile, 16000, 2, interleaved)
+
+			config := &types.Config{
+				InputFile:      inputFile,
+				InputChannels:  inputChannels,
+				MicChannel:     1,
+				SpeakerChannel: 0,
+				StereoOutput:   true,
+				OutputFile:     outputFile,
+				Mode:           types.ModeBypass,
+				OutputFormat:   types.FormatPCM16,
+				SampleRate:     16000,
+				FrameSize:      320,
+			}
+			if err := NewProcessor(config).Process(); err != nil {
+				t.Fatalf("Processor.Process() error = %v", err)
+			}
+
+			data, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if len(data) != 2*2*640 {
+				t.Fatalf("Output size = %d, want %d", len(data), 2*2*640)
+			}
+			for i := 0; i < frames; i++ {
+				left := int16(binary.LittleEndian.Uint16(data[4*i:]))
+				right := int16(binary.LittleEndian.Uint16(data[4*i+2:]))
+				if left != int16(i) || right != int16(-i) {
+					t.Fatalf("Output frame %d = (%d, %d), want (%d, %d)", i, left, right, i, -i)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessStereoMicWAV(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	createPCM16WAVFileChannels(t, micFile, 16000, 2, make([]int16, 640))
+
+	config := &types.Config{
+		MicFile:    micFile,
+		OutputFile: filepath.Join(tempDir, "output.alaw"),
+		Mode:       types.ModeBypass,
+		SampleRate: 16000,
+		FrameSize:  320,
+	}
+	err := NewProcessor(config).Process()
+	if err == nil || !strings.Contains(err.Error(), "mono") {
+		t.Errorf("Processor.Process() error = %v, want an error asking for a mono WAV file", err)
+	}
+}
+
+func TestProcessor_ProcessInterleavedChannelOutOfRange(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "stereo.wav")
+	createPCM16WAVFileChannels(t, inputFile, 16000, 2, make([]int16, 640))
+
+	config := &types.Config{
+		InputFile:      inputFile,
+		MicChannel:     0,
+		SpeakerChannel: 2,
+		OutputFile:     filepath.Join(tempDir, "output.alaw"),
+		Mode:           types.ModeAECOnly,
+		SampleRate:     16000,
+		FrameSize:      320,
+		FilterLen:      3200,
+	}
+	if err := NewProcessor(config).Process(); err == nil {
+		t.Errorf("Processor.Process() expected error for out-of-range channel")
+	}
+}
+
+func TestProcessor_ProcessWAVRateMismatch(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	createPCM16WAVFile(t, micFile, 8000, make([]int16, 320))
+
+	config := &types.Config{
+		MicFile:         micFile,
+		OutputFile:      filepath.Join(tempDir, "output.alaw"),
+		Mode:            types.ModeBypass,
+		MicRate:         16000,
+		ResampleQuality: 4,
+		SampleRate:      16000,
+		FrameSize:       320,
+	}
+	if err := NewProcessor(config).Process(); err == nil {
+		t.Errorf("Processor.Process() expected error for WAV rate conflicting with -mic-rate")
+	}
+}
+
+func TestProcessor_ProcessResample(t *testing.T) {
+	tests := []struct {
+		name        string
+		outputRate  int
+		wantRate    uint32
+		wantSamples int
+	}{
+		{name: "output at processing rate", outputRate: 0, wantRate: 16000, wantSamples: 1600},
+		{name: "output at input rate", outputRate: 8000, wantRate: 8000, wantSamples: 800},
+		{name: "output at higher rate", outputRate: 48000, wantRate: 48000, wantSamples: 4800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			micFile := filepath.Join(tempDir, "mic.wav")
+			outputFile := filepath.Join(tempDir, "output.wav")
+
+			samples := make([]int16, 800)
+			for i := range samples {
+				samples[i] = 1000
+			}
+			createPCM16WAVFile(t, micFile, 8000, samples)
+
+			config := &types.Config{
+				MicFile:         micFile,
+				OutputFile:      outputFile,
+				Mode:            types.ModeBypass,
+				OutputFormat:    types.FormatPCM16,
+				OutputRate:      tt.outputRate,
+				ResampleQuality: 4,
+				SampleRate:      16000,
+				FrameSize:       320,
+			}
+			if err := NewProcessor(config).Process(); err != nil {
+				t.Fatalf("Processor.Process() error = %v", err)
+			}
+
+			file, err := os.Open(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			defer file.Close()
+
+			header, err := audio.ReadWAVHeader(file)
+			if err != nil {
+				t.Fatalf("ReadWAVHeader() error = %v", err)
+			}
+			if header.SampleRate != tt.wantRate {
+				t.Errorf("output sample rate = %d, want %d", header.SampleRate, tt.wantRate)
+			}
+			if got := int(header.DataSize) / 2; got != tt.wantSamples {
+				t.Errorf("output samples = %d, want %d", got, tt.wantSamples)
+			}
+
+			data, err := io.ReadAll(file)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			// A constant signal must survive conversion away from the edges
+			mid := int16(binary.LittleEndian.Uint16(data[len(data)/2:]))
+			if mid < 950 || mid > 1050 {
+				t.Errorf("output sample in the middle = %d, want about 1000", mid)
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessAutoDelay(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "stereo.wav")
+	outputFile := filepath.Join(tempDir, "output.raw")
+
+	// Mic on the left carrying the reference echo 40ms late, reference on the right
+	mic, speaker := echoSignals(16000, 640)
+	interleaved := make([]int16, 2*len(mic))
+	for i := range mic {
+		interleaved[2*i] = mic[i]
+		interleaved[2*i+1] = speaker[i]
+	}
+	createPCM16WAVFileChannels(t, inputFile, 16000, 2, interleaved)
+
+	config := &types.Config{
+		InputFile:      inputFile,
+		MicChannel:     0,
+		SpeakerChannel: 1,
+		StereoOutput:   true,
+		OutputFile:     outputFile,
+		Mode:           types.ModeAECOnly,
+		OutputFormat:   types.FormatPCM16,
+		SampleRate:     16000,
+		FrameSize:      320,
+		FilterLen:      1600,
+		AutoDelay:      true,
+		MaxDelayMs:     100,
+		DelayWindowSec: 0.5,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if len(data) != 2*2*len(mic) {
+		t.Fatalf("Output size = %d, want %d", len(data), 2*2*len(mic))
+	}
+	// The reference channel is written unshifted
+	for i := range speaker {
+		if right := int16(binary.LittleEndian.Uint16(data[4*i+2:])); right != speaker[i] {
+			t.Fatalf("Output reference sample %d = %d, want %d", i, right, speaker[i])
+		}
+	}
+}
+
+func TestProcessor_ProcessMetrics(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+	metricsFile := filepath.Join(tempDir, "metrics.json")
+	csvFile := filepath.Join(tempDir, "metrics.csv")
+
+	mic, speaker := echoSignals(3200, 0)
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, speaker)
+
+	config := &types.Config{
+		MicFile:     micFile,
+		SpeakerFile: speakerFile,
+		OutputFile:  filepath.Join(tempDir, "output.alaw"),
+		Mode:        types.ModeAECOnly,
+		SampleRate:  16000,
+		FrameSize:   320,
+		FilterLen:   1600,
+		MetricsFile: metricsFile,
+		MetricsCSV:  csvFile,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	data, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	var summary metrics.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("Metrics file is not valid JSON: %v", err)
+	}
+	if summary.Frames != 10 {
+		t.Errorf("summary frames = %d, want 10", summary.Frames)
+	}
+	if summary.Activity.FarEndOnly == 0 || summary.ERLE == nil {
+		t.Errorf("summary has no far-end-only frames: %+v", summary.Activity)
+	}
+
+	rows, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatalf("Failed to read metrics CSV: %v", err)
+	}
+	if lines := bytes.Count(rows, []byte("\n")); lines != 11 {
+		t.Errorf("metrics CSV has %d lines, want 11", lines)
+	}
+}
+
+func TestProcessor_ProcessImpulseResponse(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+	irFile := filepath.Join(tempDir, "ir.csv")
+
+	mic, speaker := echoSignals(3200, 0)
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, speaker)
+
+	config := &types.Config{
+		MicFile:             micFile,
+		SpeakerFile:         speakerFile,
+		OutputFile:          filepath.Join(tempDir, "output.alaw"),
+		Mode:                types.ModeAECFirst,
+		SampleRate:          16000,
+		FrameSize:           320,
+		FilterLen:           1600,
+		ImpulseResponseFile: irFile,
+		ImpulseResponseSec:  0.08, // every 4 frames
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	// Final snapshot and two periodic ones over 10 frames
+	for _, name := range []string{"ir.csv", "ir_0001.csv", "ir_0002.csv"} {
+		rows, err := os.ReadFile(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read impulse response: %v", err)
+		}
+		if lines := bytes.Count(rows, []byte("\n")); lines != 1601 {
+			t.Errorf("%s has %d lines, want 1601", name, lines)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "ir_0003.csv")); !os.IsNotExist(err) {
+		t.Errorf("unexpected third snapshot (err = %v)", err)
+	}
+}
+
+func TestProcessor_ProcessVADTimeline(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	csvFile := filepath.Join(tempDir, "vad.csv")
+	labelFile := filepath.Join(tempDir, "speech.txt")
+
+	// 0.2s silence, 0.2s loud tone, 0.2s silence
+	mic := make([]int16, 9600)
+	for i := 3200; i < 6400; i++ {
+		mic[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+	createPCM16WAVFile(t, micFile, 16000, mic)
+
+	ns := types.DefaultConfig().NS
+	ns.EnableVAD = true
+	config := &types.Config{
+		MicFile:    micFile,
+		OutputFile: filepath.Join(tempDir, "output.alaw"),
+		Mode:       types.ModeNSOnly,
+		SampleRate: 16000,
+		FrameSize:  320,
+		NS:         ns,
+		VADCSV:     csvFile,
+		VADLabels:  labelFile,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	rows, err := os.ReadFile(csvFile)
+	if err != nil {
+		t.Fatalf("Failed to read VAD CSV: %v", err)
+	}
+	if lines := bytes.Count(rows, []byte("\n")); lines != 31 {
+		t.Errorf("VAD CSV has %d lines, want 31", lines)
+	}
+
+	labels, err := os.ReadFile(labelFile)
+	if err != nil {
+		t.Fatalf("Failed to read VAD labels: %v", err)
+	}
+	if want := "0.200000\t0.400000\tspeech 1\n"; string(labels) != want {
+		t.Errorf("VAD labels = %q, want %q", labels, want)
+	}
+}
+
+func TestProcessor_ProcessSegments(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	segmentDir := filepath.Join(tempDir, "segments")
+
+	// Speech at 0.2-0.4s and 0.8-1.0s, a 20ms click at 0.6s that is too short to keep
+	mic := make([]int16, 19200)
+	for _, burst := range [][2]int{{3200, 6400}, {9600, 9920}, {12800, 16000}} {
+		for i := burst[0]; i < burst[1]; i++ {
+			mic[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/16000))
+		}
+	}
+	createPCM16WAVFile(t, micFile, 16000, mic)
+
+	ns := types.DefaultConfig().NS
+	ns.EnableVAD = true
+	config := &types.Config{
+		MicFile:         micFile,
+		OutputFile:      filepath.Join(tempDir, "output.wav"),
+		OutputFormat:    types.FormatPCM16,
+		Mode:            types.ModeNSOnly,
+		SampleRate:      16000,
+		FrameSize:       320,
+		NS:              ns,
+		SegmentDir:      segmentDir,
+		SegmentPadMs:    40,
+		SegmentMinMs:    100,
+		SegmentMaxGapMs: 100,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(segmentDir, "segments.json"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest struct {
+		Segments []segmentEntry `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Manifest is not valid JSON: %v", err)
+	}
+	expected := []segmentEntry{
+		{Index: 1, File: "segment_0001.wav", StartSec: 0.16, EndSec: 0.44},
+		{Index: 2, File: "segment_0002.wav", StartSec: 0.76, EndSec: 1.04},
+	}
+	if len(manifest.Segments) != len(expected) {
+		t.Fatalf("manifest = %+v, want %+v", manifest.Segments, expected)
+	}
+	for i, want := range expected {
+		got := manifest.Segments[i]
+		if got.Index != want.Index || got.File != want.File ||
+			math.Abs(got.StartSec-want.StartSec) > 1e-9 || math.Abs(got.EndSec-want.EndSec) > 1e-9 {
+			t.Errorf("segment %d = %+v, want %+v", i, got, want)
+		}
+
+		info, err := os.Stat(filepath.Join(segmentDir, want.File))
+		if err != nil {
+			t.Fatalf("Failed to stat segment file: %v", err)
+		}
+		// 14 frames of PCM16 after the 44-byte WAV header
+		if size := info.Size(); size != 44+14*320*2 {
+			t.Errorf("%s size = %d, want %d", want.File, size, 44+14*320*2)
+		}
+	}
+}
+
+func TestProcessor_ProcessSegmentsSpeakerAdvance(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+	outputFile := filepath.Join(tempDir, "output.wav")
+	segmentDir := filepath.Join(tempDir, "segments")
+
+	// 0.2s silence, then speech up to the end of the input
+	mic := make([]int16, 9600)
+	for i := 3200; i < len(mic); i++ {
+		mic[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, make([]int16, len(mic)))
+
+	const delay = -100
+	ns := types.DefaultConfig().NS
+	ns.EnableVAD = true
+	config := &types.Config{
+		MicFile:         micFile,
+		SpeakerFile:     speakerFile,
+		OutputFile:      outputFile,
+		OutputFormat:    types.FormatPCM16,
+		Mode:            types.ModeAECFirst,
+		SampleRate:      16000,
+		FrameSize:       320,
+		FilterLen:       1600,
+		SpeakerDelay:    delay,
+		NS:              ns,
+		SegmentDir:      segmentDir,
+		SegmentPadMs:    40,
+		SegmentMinMs:    100,
+		SegmentMaxGapMs: 100,
+	}
+	if err := NewProcessor(config).Process(); err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+
+	// The drained mic delay line makes the output end 100 samples after the input
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to stat output: %v", err)
+	}
+	outputSamples := len(mic) - delay
+	if size := info.Size(); size != int64(44+2*outputSamples) {
+		t.Fatalf("output size = %d, want %d", size, 44+2*outputSamples)
+	}
+
+	data, err := os.ReadFile(filepath.Join(segmentDir, "segments.json"))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest struct {
+		Segments []segmentEntry `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Manifest is not valid JSON: %v", err)
+	}
+	if len(manifest.Segments) == 0 {
+		t.Fatal("manifest has no segments")
+	}
+
+	// The last segment ends with the output, and its file holds exactly its manifest span
+	last := manifest.Segments[len(manifest.Segments)-1]
+	if want := float64(outputSamples) / 16000; math.Abs(last.EndSec-want) > 1e-9 {
+		t.Errorf("last segment ends at %.5fs, want the end of the output at %.5fs", last.EndSec, want)
+	}
+	segment, err := os.Stat(filepath.Join(segmentDir, last.File))
+	if err != nil {
+		t.Fatalf("Failed to stat segment file: %v", err)
+	}
+	samples := int(math.Round((last.EndSec - last.StartSec) * 16000))
+	if size := segment.Size(); size != int64(44+2*samples) {
+		t.Errorf("%s size = %d, want %d for %d samples", last.File, size, 44+2*samples, samples)
+	}
+}
+
+func TestProcessor_ProcessVADGate(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+
+	// 0.2s background noise, 0.2s loud tone, 0.2s background noise; no echo in the reference
+	rng := rand.New(rand.NewSource(1))
+	mic := make([]int16, 9600)
+	for i := range mic {
+		mic[i] = int16(rng.Intn(200) - 100)
+		if i >= 3200 && i < 6400 {
+			mic[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/16000))
+		}
+	}
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, make([]int16, 9600))
+
+	tests := []struct {
+		name      string
+		mode      types.ProcessingMode
+		gate      types.VADGate
+		wantBytes int
+		check     func(frame []byte, speech bool) bool
+	}{
+		{
+			name:      "silence",
+			mode:      types.ModeNSOnly,
+			gate:      types.GateSilence,
+			wantBytes: 9600,
+			check: func(frame []byte, speech bool) bool {
+				return speech || bytes.Count(frame, []byte{0xD5}) == len(frame)
+			},
+		},
+		{
+			name:      "comfort noise",
+			mode:      types.ModeNSOnly,
+			gate:      types.GateNoise,
+			wantBytes: 9600,
+			check: func(frame []byte, speech bool) bool {
+				if speech {
+					return true
+				}
+				pcm := make([]int16, len(frame))
+				audio.AlawBufferToPCM16(frame, pcm)
+				peak := 0
+				for _, v := range pcm {
+					peak = max(peak, abs(int(v)))
+				}
+				return peak > 8 && peak < 1000
+			},
+		},
+		{
+			name:      "drop",
+			mode:      types.ModeNSOnly,
+			gate:      types.GateDrop,
+			wantBytes: 3200,
+			check:     func(frame []byte, speech bool) bool { return true },
+		},
+		{
+			name:      "drop after aec only",
+			mode:      types.ModeAECOnly,
+			gate:      types.GateDrop,
+			wantBytes: 3200,
+			check:     func(frame []byte, speech bool) bool { return true },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputFile := filepath.Join(tempDir, tt.name+".alaw")
+			editList := ""
+			if tt.gate == types.GateDrop {
+				editList = filepath.Join(tempDir, tt.name+".csv")
+			}
+			config := &types.Config{
+				MicFile:        micFile,
+				SpeakerFile:    speakerFile,
+				OutputFile:     outputFile,
+				Mode:           tt.mode,
+				SampleRate:     16000,
+				FrameSize:      320,
+				FilterLen:      1600,
+				NS:             types.DefaultConfig().NS,
+				VADGate:        tt.gate,
+				ComfortNoiseDB: -60,
+				VADEditList:    editList,
+			}
+			config.NS.EnableVAD = true
+			if err := NewProcessor(config).Process(); err != nil {
+				t.Fatalf("Processor.Process() error = %v", err)
+			}
+
+			output, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if len(output) != tt.wantBytes {
+				t.Fatalf("output has %d bytes, want %d", len(output), tt.wantBytes)
+			}
+			for frame := 0; frame < len(output)/320; frame++ {
+				speech := tt.gate == types.GateDrop || (frame >= 10 && frame < 20)
+				if !tt.check(output[frame*320:(frame+1)*320], speech) {
+					t.Errorf("frame %d not gated as expected", frame)
+				}
+			}
+
+			if editList != "" {
+				edits, err := os.ReadFile(editList)
+				if err != nil {
+					t.Fatalf("Failed to read edit list: %v", err)
+				}
+				want := "source_start_sec,source_end_sec,output_start_sec,duration_sec\n0.200,0.400,0.000,0.200\n"
+				if string(edits) != want {
+					t.Errorf("edit list = %q, want %q", edits, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessComfortNoise(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+
+	// One second of background noise only
+	rng := rand.New(rand.NewSource(1))
+	mic := make([]int16, 16000)
+	for i := range mic {
+		mic[i] = int16(rng.Intn(2000) - 1000)
+	}
+	createPCM16WAVFile(t, micFile, 16000, mic)
+
+	// Output level relative to the input in dB
+	process := func(name string, comfortFill bool, offsetDB float64) float64 {
+		outputFile := filepath.Join(tempDir, name+".raw")
+		config := &types.Config{
+			MicFile:              micFile,
+			OutputFile:           outputFile,
+			OutputFormat:         types.FormatPCM16,
+			Mode:                 types.ModeNSOnly,
+			SampleRate:           16000,
+			FrameSize:            320,
+			NS:                   types.DefaultConfig().NS,
+			ComfortFill:          comfortFill,
+			ComfortNoiseOffsetDB: offsetDB,
+		}
+		if err := NewProcessor(config).Process(); err != nil {
+			t.Fatalf("Processor.Process() error = %v", err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		var in, out float64
+		for i := range mic {
+			v := float64(int16(binary.LittleEndian.Uint16(data[2*i:])))
+			out += v * v
+			in += float64(mic[i]) * float64(mic[i])
+		}
+		return 10 * math.Log10(out/in)
+	}
+
+	suppressed := process("suppressed", false, 0)
+	filled := process("filled", true, 0)
+	if suppressed > -3 {
+		t.Fatalf("noise suppression left %.1f dB, the test needs a suppressed background", suppressed)
+	}
+	// The fill tops up to the suppressed background, never back to the input noise floor
+	if filled > -3 {
+		t.Errorf("comfort noise output level = %.1f dB relative to the input background, want below it", filled)
+	}
+	if filled < suppressed-0.5 {
+		t.Errorf("comfort noise output level = %.1f dB, below the %.1f dB left by noise suppression", filled, suppressed)
+	}
+
+	// The offset is relative to the suppressed background (-15 dB by default)
+	raised := process("raised", true, 10)
+	if want := types.DefaultConfig().NS.NoiseSuppress + 10; math.Abs(raised-want) > 1.5 {
+		t.Errorf("comfort noise output level with +10 dB offset = %.1f dB, want about %.1f dB", raised, want)
+	}
+}
+
+func TestProcessor_ProcessMultiChannel(t *testing.T) {
+	const frameSize = 320
+	const samples = 4 * frameSize
+	mics := [][]int16{constantSamples(samples, 1000), constantSamples(samples, 2000)}
+	speakers := [][]int16{constantSamples(samples, 400), constantSamples(samples, 400)}
+
+	tests := []struct {
+		name        string
+		interleaved bool
+	}{
+		{name: "separate files"},
+		{name: "interleaved input", interleaved: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			outputFile := filepath.Join(tempDir, "output.raw")
+			config := &types.Config{
+				OutputFile:   outputFile,
+				Mode:         types.ModeAECOnly,
+				OutputFormat: types.FormatPCM16,
+				SampleRate:   16000,
+				FrameSize:    frameSize,
+				FilterLen:    4 * frameSize,
+			}
+
+			if tt.interleaved {
+				// Channels: mic 0, speaker 0, mic 1, speaker 1
+				inputFile := filepath.Join(tempDir, "input.wav")
+				pcm := make([]int16, 4*samples)
+				for i := 0; i < samples; i++ {
+					pcm[4*i] = mics[0][i]
+					pcm[4*i+1] = speakers[0][i]
+					pcm[4*i+2] = mics[1][i]
+					pcm[4*i+3] = speakers[1][i]
+				}
+				createPCM16WAVFileChannels(t, inputFile, 16000, 4, pcm)
+				config.InputFile = inputFile
+				config.MicChannel, config.SpeakerChannel = 0, 1
+				config.ExtraMicChannels = []int{2}
+				config.ExtraSpeakerChannels = []int{3}
+			} else {
+				files := make([]string, 4)
+				for i := range files {
+					files[i] = filepath.Join(tempDir, fmt.Sprintf("input%d.wav", i))
+				}
+				createPCM16WAVFile(t, files[0], 16000, mics[0])
+				createPCM16WAVFile(t, files[1], 16000, mics[1])
+				createPCM16WAVFile(t, files[2], 16000, speakers[0])
+				createPCM16WAVFile(t, files[3], 16000, speakers[1])
+				config.MicFile, config.ExtraMicFiles = files[0], files[1:2]
+				config.SpeakerFile, config.ExtraSpeakerFiles = files[2], files[3:]
+			}
+
+			if err := NewProcessor(config).Process(); err != nil {
+				t.Fatalf("Processor.Process() error = %v", err)
+			}
+
+			data, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output: %v", err)
+			}
+			if len(data) != 2*2*samples {
+				t.Fatalf("Output size = %d, want %d", len(data), 2*2*samples)
+			}
+			// Each mic channel loses the echo of both speakers
+			for i := 0; i < samples; i++ {
+				for ch, want := range []int16{1000 - 400, 2000 - 400} {
+					got := int16(binary.LittleEndian.Uint16(data[4*i+2*ch:]))
+					if got != want {
+						t.Fatalf("Output sample %d channel %d = %d, want %d", i, ch, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessStreams(t *testing.T) {
+	// Mic carries the speaker at half level, which the echo canceller removes
+	mic, speaker := echoSignals(3200, 0)
+	var micWAV, speakerWAV, output bytes.Buffer
+	writePCM16WAV(t, &micWAV, mic)
+	writePCM16WAV(t, &speakerWAV, speaker)
+
+	config := &types.Config{
+		Mode:         types.ModeAECOnly,
+		OutputFormat: types.FormatPCM16,
+		SampleRate:   16000,
+		FrameSize:    320,
+		FilterLen:    1600,
+	}
+	streams := types.Streams{
+		Mic:       &micWAV,
+		Speaker:   &speakerWAV,
+		Output:    &output,
+		OutputWAV: true,
+	}
+	if err := NewProcessor(config).ProcessStreams(context.Background(), streams); err != nil {
+		t.Fatalf("Processor.ProcessStreams() error = %v", err)
+	}
+
+	header, err := audio.ReadWAVHeader(&output)
+	if err != nil {
+		t.Fatalf("Output is not a WAV stream: %v", err)
+	}
+	if header.AudioFormat != audio.WAVFormatPCM || header.SampleRate != 16000 {
+		t.Errorf("Output header = %+v, want PCM at 16000 Hz", header)
+	}
+	samples := make([]int16, len(mic))
+	if err := binary.Read(&output, binary.LittleEndian, samples); err != nil {
+		t.Fatalf("Failed to read output samples: %v", err)
+	}
+	for i, s := range samples {
+		if s != 0 {
+			t.Fatalf("Output sample %d = %d, want 0", i, s)
+		}
+	}
+}
+
+func TestProcessor_ProcessAllocsPerFrame(t *testing.T) {
+	modes := []types.ProcessingMode{types.ModeTestAlaw, types.ModeNSOnly, types.ModeAECOnly, types.ModeNSFirst, types.ModeAECFirst}
+	for _, mode := range modes {
+		t.Run(mode.String(), func(t *testing.T) {
+			// Allocations of a run do not depend on its length once the buffers are set up
+			short := processAllocs(t, mode, 10)
+			long := processAllocs(t, mode, 110)
+			if perFrame := (long - short) / 100; perFrame != 0 {
+				t.Errorf("processing allocates %.2f times per frame, want 0", perFrame)
+			}
+		})
+	}
+}
+
+// processAllocs returns the average allocations of processing a number of 20 ms frames
+func processAllocs(t *testing.T, mode types.ProcessingMode, frames int) float64 {
+	t.Helper()
+	config, streams := benchStreams(t, mode, frames)
+	return testing.AllocsPerRun(5, func() {
+		if err := NewProcessor(config).ProcessStreams(context.Background(), streams()); err != nil {
+			t.Fatalf("Processor.ProcessStreams() error = %v", err)
+		}
+	})
+}
+
+// benchStreams returns a configuration and a factory of fresh in-memory streams of a number of frames
+func benchStreams(tb testing.TB, mode types.ProcessingMode, frames int) (*types.Config, func() types.Streams) {
+	tb.Helper()
+	mic, speaker := echoSignals(frames*320, 0)
+	var micWAV, speakerWAV bytes.Buffer
+	writePCM16WAV(tb, &micWAV, mic)
+	writePCM16WAV(tb, &speakerWAV, speaker)
+
+	config := &types.Config{
+		Mode:         mode,
+		OutputFormat: types.FormatPCM16,
+		SampleRate:   16000,
+		FrameSize:    320,
+		FilterLen:    1600,
+		NS:           types.DefaultConfig().NS,
+	}
+	return config, func() types.Streams {
+		return types.Streams{
+			Mic:     bytes.NewReader(micWAV.Bytes()),
+			Speaker: bytes.NewReader(speakerWAV.Bytes()),
+			Output:  io.Discard,
+		}
+	}
+}
+
+func BenchmarkProcessor_ProcessStreams(b *testing.B) {
+	const frames = 500
+	for _, mode := range []types.ProcessingMode{types.ModeAECOnly, types.ModeAECFirst} {
+		b.Run(mode.String(), func(b *testing.B) {
+			config, streams := benchStreams(b, mode, frames)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := NewProcessor(config).ProcessStreams(context.Background(), streams()); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*frames), "ns/frame")
+		})
+	}
+}
+
+func TestProcessor_ProcessStdio(t *testing.T) {
+	input := bytes.Repeat([]byte{0x55, 0xD5, 0x2A, 0xAA}, 320)
+
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdinReader, stdoutWriter
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	go func() {
+		stdinWriter.Write(input)
+		stdinWriter.Close()
+	}()
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(stdoutReader)
+		output <- data
+	}()
+
+	config := &types.Config{
+		MicFile:    types.StdioPath,
+		OutputFile: types.StdioPath,
+		Mode:       types.ModeBypass,
+		SampleRate: 16000,
+		FrameSize:  320,
+	}
+	err = NewProcessor(config).Process()
+	stdoutWriter.Close()
+	if err != nil {
+		t.Fatalf("Processor.Process() error = %v", err)
+	}
+	if data := <-output; !bytes.Equal(data, input) {
+		t.Errorf("stdout carries %d bytes, want the %d input bytes unchanged", len(data), len(input))
+	}
+}
+
+func TestProcessor_ProcessContextCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.alaw")
+	createDummyAlawFile(t, micFile, 3200)
+
+	config := &types.Config{
+		MicFile:    micFile,
+		OutputFile: filepath.Join(tempDir, "output.alaw"),
+		Mode:       types.ModeBypass,
+		SampleRate: 16000,
+		FrameSize:  320,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewProcessor(config).ProcessContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Processor.ProcessContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestProcessor_AECFirstHonoursNSConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+
+	mic, speaker := echoSignals(16000, 160)
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, speaker)
+
+	process := func(name string, ns types.NSConfig) []byte {
+		outputFile := filepath.Join(tempDir, name+".raw")
+		config := &types.Config{
+			MicFile:      micFile,
+			SpeakerFile:  speakerFile,
+			OutputFile:   outputFile,
+			OutputFormat: types.FormatPCM16,
+			Mode:         types.ModeAECFirst,
+			SampleRate:   16000,
+			FrameSize:    320,
+			FilterLen:    1600,
+			NS:           ns,
+		}
+		if err := NewProcessor(config).Process(); err != nil {
+			t.Fatalf("Processor.Process() error = %v", err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		return data
+	}
+
+	base := types.DefaultConfig().NS
+	baseline := process("baseline", base)
+
+	tests := []struct {
+		name   string
+		modify func(ns *types.NSConfig)
+	}{
+		{name: "noise suppress", modify: func(ns *types.NSConfig) { ns.NoiseSuppress = -40 }},
+		{name: "agc", modify: func(ns *types.NSConfig) { ns.EnableAGC = true }},
+		{name: "echo suppress", modify: func(ns *types.NSConfig) { ns.EchoSuppress = -90 }},
+		{name: "echo suppress off", modify: func(ns *types.NSConfig) { ns.EchoSuppress = 0 }},
+		{name: "denoise off", modify: func(ns *types.NSConfig) { ns.EnableDenoise = false }},
+		{name: "dereverb", modify: func(ns *types.NSConfig) { ns.EnableDereverb = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := base
+			tt.modify(&ns)
+			if bytes.Equal(process(tt.name, ns), baseline) {
+				t.Errorf("output with modified %s is identical to the default output", tt.name)
+			}
+		})
+	}
+
+	t.Run("agc level", func(t *testing.T) {
+		ns := base
+		ns.EnableAGC = true
+		ns.AGCLevel = 8000
+		low := process("agc-low", ns)
+		ns.AGCLevel = 24000
+		if bytes.Equal(process("agc-high", ns), low) {
+			t.Errorf("output does not depend on the AGC level")
+		}
+	})
+}
+
+func TestProcessor_ChainMatchesMode(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.wav")
+	speakerFile := filepath.Join(tempDir, "speaker.wav")
+
+	mic, speaker := echoSignals(16000, 160)
+	createPCM16WAVFile(t, micFile, 16000, mic)
+	createPCM16WAVFile(t, speakerFile, 16000, speaker)
+
+	process := func(name string, mode types.ProcessingMode, chain []string) []byte {
+		outputFile := filepath.Join(tempDir, name+".raw")
+		config := &types.Config{
+			MicFile:      micFile,
+			SpeakerFile:  speakerFile,
+			OutputFile:   outputFile,
+			OutputFormat: types.FormatPCM16,
+			Mode:         mode,
+			Chain:        chain,
+			HighPassHz:   100,
+			GainDB:       6,
+			SampleRate:   16000,
+			FrameSize:    320,
+			FilterLen:    1600,
+			NS:           types.DefaultConfig().NS,
+		}
+		if err := NewProcessor(config).Process(); err != nil {
+			t.Fatalf("Processor.Process() error = %v", err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		return data
+	}
+
+	modes := []types.ProcessingMode{types.ModeTestAlaw, types.ModeNSOnly, types.ModeAECOnly, types.ModeNSFirst, types.ModeAECFirst}
+	for _, mode := range modes {
+		t.Run(mode.String(), func(t *testing.T) {
+			want := process(mode.String()+"-mode", mode, nil)
+			got := process(mode.String()+"-chain", types.ModeBypass, mode.Chain())
+			if !bytes.Equal(got, want) {
+				t.Errorf("chain %v output differs from %s mode output", mode.Chain(), mode)
+			}
+		})
+	}
+
+	t.Run("hpf and gain", func(t *testing.T) {
+		plain := process("plain", types.ModeBypass, []string{types.StageAEC})
+		if bytes.Equal(process("extended", types.ModeBypass, []string{types.StageHPF, types.StageAEC, types.StageGain}), plain) {
+			t.Errorf("extra hpf and gain stages did not change the output")
+		}
+	})
+}
+
 func TestProcessor_needsSpeakerFile(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -126,6 +1300,16 @@ func TestProcessor_needsSpeakerFile(t *testing.T) {
 			config: &types.Config{Mode: types.ModeAECFirst},
 			want:   true,
 		},
+		{
+			name:   "chain without aec",
+			config: &types.Config{Mode: types.ModeAECFirst, Chain: []string{types.StageHPF, types.StageNS}},
+			want:   false,
+		},
+		{
+			name:   "chain with aec",
+			config: &types.Config{Mode: types.ModeBypass, Chain: []string{types.StageHPF, types.StageAEC}},
+			want:   true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -138,52 +1322,100 @@ func TestProcessor_needsSpeakerFile(t *testing.T) {
 	}
 }
 
-func TestProcessor_getAECSpeakerFrame(t *testing.T) {
-	speakerFrame := []int16{1, 2, 3, 4, 5}
-	prevSpeakerFrame := []int16{10, 20, 30, 40, 50}
+func TestDelayStage_process(t *testing.T) {
+	const frameSize = 4
+	mic := []int16{1, 2, 3, 4, 5, 6, 7, 8}
+	speaker := []int16{10, 20, 30, 40, 50, 60, 70, 80}
 
 	tests := []struct {
-		name             string
-		config           *types.Config
-		speakerFrame     []int16
-		prevSpeakerFrame []int16
-		want             []int16
+		name        string
+		delay       int
+		wantMic     []int16
+		wantSpeaker []int16
 	}{
 		{
-			name:             "use current speaker frame",
-			config:           &types.Config{UsePrevSpeaker: false},
-			speakerFrame:     speakerFrame,
-			prevSpeakerFrame: prevSpeakerFrame,
-			want:             speakerFrame,
+			name:        "previous speaker frame",
+			delay:       frameSize,
+			wantMic:     []int16{1, 2, 3, 4, 5, 6, 7, 8},
+			wantSpeaker: []int16{0, 0, 0, 0, 10, 20, 30, 40},
+		},
+		{
+			name:        "speaker delay within a frame",
+			delay:       1,
+			wantMic:     []int16{1, 2, 3, 4, 5, 6, 7, 8},
+			wantSpeaker: []int16{0, 10, 20, 30, 40, 50, 60, 70},
 		},
 		{
-			name:             "use previous speaker frame",
-			config:           &types.Config{UsePrevSpeaker: true},
-			speakerFrame:     speakerFrame,
-			prevSpeakerFrame: prevSpeakerFrame,
-			want:             prevSpeakerFrame,
+			name:        "speaker advance delays the mic",
+			delay:       -3,
+			wantMic:     []int16{0, 0, 0, 1, 2, 3, 4, 5},
+			wantSpeaker: []int16{10, 20, 30, 40, 50, 60, 70, 80},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			processor := &Processor{config: tt.config}
-			got := processor.getAECSpeakerFrame(tt.speakerFrame, tt.prevSpeakerFrame)
-
-			if len(got) != len(tt.want) {
-				t.Errorf("Processor.getAECSpeakerFrame() length = %d, want %d", len(got), len(tt.want))
-				return
+			stage := newDelayStage(abs(tt.delay), frameSize)
+			stage.setDelay(tt.delay)
+			if got := stage.delay(); got != tt.delay {
+				t.Errorf("delayStage.delay() = %d, want %d", got, tt.delay)
 			}
 
-			for i := range got {
-				if got[i] != tt.want[i] {
-					t.Errorf("Processor.getAECSpeakerFrame()[%d] = %d, want %d", i, got[i], tt.want[i])
+			for f := 0; f < len(mic)/frameSize; f++ {
+				gotMic, gotSpeaker := stage.process(mic[f*frameSize:(f+1)*frameSize], speaker[f*frameSize:(f+1)*frameSize])
+				for i := 0; i < frameSize; i++ {
+					if gotMic[i] != tt.wantMic[f*frameSize+i] || gotSpeaker[i] != tt.wantSpeaker[f*frameSize+i] {
+						t.Fatalf("frame %d = %v/%v, want %v/%v", f, gotMic, gotSpeaker,
+							tt.wantMic[f*frameSize:(f+1)*frameSize], tt.wantSpeaker[f*frameSize:(f+1)*frameSize])
+					}
 				}
 			}
 		})
 	}
 }
 
+func TestProcessor_ProcessSpeakerAdvanceDrainsMic(t *testing.T) {
+	const frameSize = 320
+	for _, delay := range []int{-100, -frameSize, -500} {
+		t.Run(fmt.Sprintf("%d samples", -delay), func(t *testing.T) {
+			// Speaker silence leaves the mic unchanged by the echo canceller
+			mic := make([]int16, 10*frameSize)
+			for i := range mic {
+				mic[i] = int16(i%1000 + 1)
+			}
+			var micWAV, speakerWAV, output bytes.Buffer
+			writePCM16WAV(t, &micWAV, mic)
+			writePCM16WAV(t, &speakerWAV, make([]int16, len(mic)))
+
+			config := &types.Config{
+				Mode:         types.ModeAECOnly,
+				OutputFormat: types.FormatPCM16,
+				SampleRate:   16000,
+				FrameSize:    frameSize,
+				FilterLen:    1600,
+				SpeakerDelay: delay,
+			}
+			streams := types.Streams{Mic: &micWAV, Speaker: &speakerWAV, Output: &output}
+			if err := NewProcessor(config).ProcessStreams(context.Background(), streams); err != nil {
+				t.Fatalf("Processor.ProcessStreams() error = %v", err)
+			}
+
+			// The output is the mic delayed by the advance, with every input sample
+			samples := make([]int16, output.Len()/2)
+			if err := binary.Read(&output, binary.LittleEndian, samples); err != nil {
+				t.Fatalf("Failed to read output samples: %v", err)
+			}
+			if len(samples) != len(mic)-delay {
+				t.Fatalf("output length = %d samples, want input length %d plus delay %d", len(samples), len(mic), -delay)
+			}
+			if got := samples[-delay:]; len(got) != len(mic) || got[len(got)-1] == 0 {
+				t.Errorf("delayed mic = %d samples ending in %d, want %d samples ending in %d",
+					len(got), got[len(got)-1], len(mic), mic[len(mic)-1])
+			}
+		})
+	}
+}
+
 // Helper function to create dummy A-law files
 func createDummyAlawFile(t *testing.T, filename string, size int) {
 	t.Helper()
@@ -204,3 +1436,60 @@ func createDummyAlawFile(t *testing.T, filename string, size int) {
 		t.Fatalf("Failed to write test data to %s: %v", filename, err)
 	}
 }
+
+// Helper function to create mono PCM16 WAV files
+func createPCM16WAVFile(t *testing.T, filename string, sampleRate int, samples []int16) {
+	t.Helper()
+	createPCM16WAVFileChannels(t, filename, sampleRate, 1, samples)
+}
+
+// Helper function to write a mono 16 kHz PCM16 WAV stream
+func writePCM16WAV(t testing.TB, w io.Writer, samples []int16) {
+	t.Helper()
+
+	ww, err := audio.NewWAVWriter(w, audio.WAVFormatPCM, 16000, 1)
+	if err != nil {
+		t.Fatalf("Failed to write WAV header: %v", err)
+	}
+	if err := binary.Write(ww, binary.LittleEndian, samples); err != nil {
+		t.Fatalf("Failed to write test data: %v", err)
+	}
+	if err := ww.Close(); err != nil {
+		t.Fatalf("Failed to finalize WAV stream: %v", err)
+	}
+}
+
+// Helper function to create interleaved PCM16 WAV files
+func createPCM16WAVFileChannels(t *testing.T, filename string, sampleRate, channels int, samples []int16) {
+	t.Helper()
+
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Failed to create test file %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	ww, err := audio.NewWAVWriter(file, audio.WAVFormatPCM, sampleRate, channels)
+	if err != nil {
+		t.Fatalf("Failed to write WAV header to %s: %v", filename, err)
+	}
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	if _, err := ww.Write(data); err != nil {
+		t.Fatalf("Failed to write test data to %s: %v", filename, err)
+	}
+	if err := ww.Close(); err != nil {
+		t.Fatalf("Failed to finalize %s: %v", filename, err)
+	}
+}
+
+// Helper function to create a constant signal
+func constantSamples(n int, value int16) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = value
+	}
+	return samples
+}
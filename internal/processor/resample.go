@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"open_tool_speex/pkg/speex"
+)
+
+// rateConverter converts a continuous interleaved stream between two sample rates
+// The resampler latency is compensated so that output sample n lines up with input
+// sample n*inRate/outRate and the total output length matches the rate ratio
+type rateConverter struct {
+	resampler *speex.Resampler
+	channels  int
+	inRate    int
+	outRate   int
+	inTotal   int64   // input samples per channel fed so far
+	outTotal  int64   // output samples per channel emitted so far
+	scratch   []int16 // resampler output buffer
+}
+
+// newRateConverter creates a converter between two rates
+func newRateConverter(channels, inRate, outRate, quality int) (*rateConverter, error) {
+	resampler, err := speex.NewResampler(channels, inRate, outRate, quality)
+	if err != nil {
+		return nil, err
+	}
+	resampler.SkipZeros()
+
+	return &rateConverter{
+		resampler: resampler,
+		channels:  channels,
+		inRate:    inRate,
+		outRate:   outRate,
+	}, nil
+}
+
+// convert resamples all of in and appends the output to dst
+func (rc *rateConverter) convert(dst, in []int16) ([]int16, error) {
+	rc.inTotal += int64(len(in) / rc.channels)
+	return rc.process(dst, in, rc.expectedOutput())
+}
+
+// flush drains the resampler history and appends the remaining output to dst
+func (rc *rateConverter) flush(dst []int16) ([]int16, error) {
+	zeros := make([]int16, (rc.resampler.InputLatency()+1)*rc.channels)
+	return rc.process(dst, zeros, rc.expectedOutput())
+}
+
+// expectedOutput returns the output length per channel matching the input fed so far
+func (rc *rateConverter) expectedOutput() int64 {
+	return rc.inTotal * int64(rc.outRate) / int64(rc.inRate)
+}
+
+// process feeds in to the resampler, appending output to dst and stopping once
+// outLimit samples per channel have been emitted
+func (rc *rateConverter) process(dst, in []int16, outLimit int64) ([]int16, error) {
+	for len(in) >= rc.channels {
+		want := (len(in)/rc.channels)*rc.outRate/rc.inRate + 2
+		if cap(rc.scratch) < want*rc.channels {
+			rc.scratch = make([]int16, want*rc.channels)
+		}
+		scratch := rc.scratch[:want*rc.channels]
+
+		consumed, produced, err := rc.resampler.Process(in, scratch)
+		if err != nil {
+			return dst, err
+		}
+		if rc.outTotal+int64(produced) > outLimit {
+			produced = int(max(outLimit-rc.outTotal, 0))
+		}
+		dst = append(dst, scratch[:produced*rc.channels]...)
+		rc.outTotal += int64(produced)
+
+		if consumed == 0 && produced == 0 {
+			break
+		}
+		in = in[consumed*rc.channels:]
+	}
+	return dst, nil
+}
+
+// Destroy cleans up resources
+func (rc *rateConverter) Destroy() {
+	rc.resampler.Destroy()
+}
@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"open_tool_speex/internal/vad"
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/speex"
+)
+
+// segmentManifest is the name of the manifest written to the segment directory
+const segmentManifest = "segments.json"
+
+// segmentEntry describes one segment file in the manifest
+type segmentEntry struct {
+	Index    int     `json:"index"`
+	File     string  `json:"file"`
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+// segmentWriter splits the processed output into one file per speech segment
+// Output frames are queued until the segmenter assigns them, then written to their segment file
+type segmentWriter struct {
+	p         *Processor
+	segmenter *vad.Segmenter
+	dir       string
+	ext       string
+	channels  int
+	pending   [][]int16 // queued frames, channels concatenated
+	base      int       // frame index of pending[0]
+	frames    int       // frames added so far
+	samples   int       // samples per channel added so far (the last frame may be short)
+	current   int       // segment being written (0 = none)
+	file      *os.File
+	writer    *audioWriter
+	entries   []segmentEntry
+}
+
+// newSegmentWriter creates the segment directory, or returns nil if segmentation is disabled
+func (p *Processor) newSegmentWriter(channels int) (*segmentWriter, error) {
+	if p.config.SegmentDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(p.config.SegmentDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create segment directory: %w", err)
+	}
+
+	ext := filepath.Ext(p.config.OutputFile)
+	if ext == "" {
+		ext = "." + p.config.OutputFormat.String()
+	}
+
+	return &segmentWriter{
+		p:         p,
+		segmenter: vad.NewSegmenter(p.msToFrames(p.config.SegmentPadMs), p.msToFrames(p.config.SegmentMinMs), p.msToFrames(p.config.SegmentMaxGapMs)),
+		dir:       p.config.SegmentDir,
+		ext:       ext,
+		channels:  channels,
+	}, nil
+}
+
+// msToFrames converts a duration in milliseconds to whole frames
+func (p *Processor) msToFrames(ms int) int {
+	return int(math.Round(float64(ms) * float64(p.config.SampleRate) / 1000 / float64(p.config.FrameSize)))
+}
+
+// add queues an output frame with its voice activity and writes the frames assigned so far
+func (sw *segmentWriter) add(result speex.VADResult, channels ...[]int16) error {
+	frame := make([]int16, 0, len(channels[0])*len(channels))
+	for _, ch := range channels {
+		frame = append(frame, ch...)
+	}
+	sw.pending = append(sw.pending, frame)
+	sw.frames++
+	sw.samples += len(channels[0])
+	return sw.write(sw.segmenter.Add(result.Speech))
+}
+
+// write writes assigned frames to their segment files and drops them from the queue
+func (sw *segmentWriter) write(spans []vad.Span) error {
+	for _, span := range spans {
+		if span.Segment != sw.current {
+			if err := sw.closeSegment(); err != nil {
+				return err
+			}
+			if span.Segment != 0 {
+				if err := sw.openSegment(span.Segment, span.Start); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := span.Start; i < span.End; i++ {
+			frame := sw.pending[i-sw.base]
+			if sw.writer != nil {
+				if err := sw.writer.writeFrame(sw.split(frame)...); err != nil {
+					return fmt.Errorf("failed to write segment %d: %w", sw.current, err)
+				}
+			}
+		}
+		if sw.current != 0 {
+			sw.entries[len(sw.entries)-1].EndSec = sw.frameTime(span.End)
+		}
+
+		sw.pending = sw.pending[span.End-sw.base:]
+		sw.base = span.End
+	}
+	return nil
+}
+
+// split returns the channels of a queued frame
+func (sw *segmentWriter) split(frame []int16) [][]int16 {
+	size := len(frame) / sw.channels
+	channels := make([][]int16, sw.channels)
+	for ch := range channels {
+		channels[ch] = frame[ch*size : (ch+1)*size]
+	}
+	return channels
+}
+
+// openSegment creates the file of a segment starting at the given frame
+func (sw *segmentWriter) openSegment(segment, start int) error {
+	name := fmt.Sprintf("segment_%04d%s", segment, sw.ext)
+	file, err := os.Create(filepath.Join(sw.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create segment file: %w", err)
+	}
+	writer, err := sw.p.newAudioWriter(file, audio.HasWAVExtension(name), sw.channels)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to initialize segment output: %w", err)
+	}
+
+	sw.file = file
+	sw.writer = writer
+	sw.current = segment
+	sw.entries = append(sw.entries, segmentEntry{
+		Index:    segment,
+		File:     name,
+		StartSec: sw.frameTime(start),
+		EndSec:   sw.frameTime(start),
+	})
+	return nil
+}
+
+// closeSegment finalizes the current segment file
+func (sw *segmentWriter) closeSegment() error {
+	if sw.file == nil {
+		sw.current = 0
+		return nil
+	}
+	err := sw.writer.Close()
+	if closeErr := sw.file.Close(); err == nil {
+		err = closeErr
+	}
+	sw.file = nil
+	sw.writer = nil
+	sw.current = 0
+	if err != nil {
+		return fmt.Errorf("failed to finalize segment: %w", err)
+	}
+	return nil
+}
+
+// finish writes the remaining frames and the manifest
+func (sw *segmentWriter) finish() error {
+	if err := sw.write(sw.segmenter.Flush()); err != nil {
+		return err
+	}
+	if err := sw.closeSegment(); err != nil {
+		return err
+	}
+
+	entries := sw.entries
+	if entries == nil {
+		entries = []segmentEntry{}
+	}
+	file, err := os.Create(filepath.Join(sw.dir, segmentManifest))
+	if err != nil {
+		return fmt.Errorf("failed to create segment manifest: %w", err)
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Segments []segmentEntry `json:"segments"`
+	}{entries}); err != nil {
+		return fmt.Errorf("failed to write segment manifest: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Segments: %d files written to %s\n", len(sw.entries), sw.dir)
+	return file.Close()
+}
+
+// Close releases an unfinished segment file
+func (sw *segmentWriter) Close() error {
+	if sw.writer != nil {
+		sw.writer.destroy()
+	}
+	if sw.file != nil {
+		return sw.file.Close()
+	}
+	return nil
+}
+
+// frameTime returns the start time of a frame in seconds
+// The end of the last added frame is its actual length, which may be short of a full frame
+func (sw *segmentWriter) frameTime(frame int) float64 {
+	samples := frame * sw.p.config.FrameSize
+	if frame >= sw.frames {
+		samples = sw.samples
+	}
+	return float64(samples) / float64(sw.p.config.SampleRate)
+}
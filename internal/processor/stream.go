@@ -0,0 +1,535 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/types"
+)
+
+// audioReader reads encoded, possibly interleaved, samples from a raw or WAV input stream
+// and delivers frames at the processing rate
+type audioReader struct {
+	r        io.Reader
+	codec    audio.Codec
+	channels int
+	rate     int     // stream sample rate in Hz
+	buf      []byte  // encoded interleaved block at the stream rate
+	pcm      []int16 // decoded interleaved frame at the processing rate
+
+	// Sample-rate conversion (nil if the stream is at the processing rate)
+	converter *rateConverter
+	block     []int16 // decoded block at the stream rate
+	ready     []int16 // converted samples not yet delivered
+	eof       bool
+}
+
+// audioWriter writes encoded, possibly interleaved, samples to a raw or WAV output stream
+type audioWriter struct {
+	w        io.Writer
+	wav      *audio.WAVWriter
+	codec    audio.Codec
+	channels int
+	rate     int     // stream sample rate in Hz
+	pcm      []int16 // interleaved frame at the processing rate
+	buf      []byte  // encoded output
+
+	// Sample-rate conversion (nil if the stream is at the processing rate)
+	converter *rateConverter
+	converted []int16 // interleaved samples at the stream rate
+}
+
+// audioInputs provides time-aligned mic and speaker frames, either from mono files
+// or from channels of one interleaved file
+type audioInputs struct {
+	readers  []namedReader   // distinct readers, each read once per frame
+	mics     []channelSource // mic channels
+	speakers []channelSource // speaker channels (empty if no reference is needed)
+	files    []*os.File
+}
+
+// namedReader is an input reader with the stream name used in errors
+type namedReader struct {
+	reader *audioReader
+	name   string
+}
+
+// channelSource selects one channel of an input reader
+type channelSource struct {
+	reader  *audioReader
+	channel int
+}
+
+// openInputs opens the mic and speaker inputs described by the configuration
+func (p *Processor) openInputs() (*audioInputs, error) {
+	in := &audioInputs{}
+	needSpeaker := p.needsSpeakerFile() || p.config.StereoOutput
+
+	if p.config.InputFile != "" {
+		// Single interleaved input carrying both mic and reference
+		file, err := in.open(p.config.InputFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+
+		if err := in.addInterleaved(p, file, p.config.InputFile, "input file", needSpeaker); err != nil {
+			in.Close()
+			return nil, err
+		}
+		return in, nil
+	}
+
+	for i, path := range append([]string{p.config.MicFile}, p.config.ExtraMicFiles...) {
+		reader, err := in.openFile(p, path, channelName("mic", i), p.config.MicFormat, p.config.MicRate)
+		if err != nil {
+			in.Close()
+			return nil, err
+		}
+		in.mics = append(in.mics, channelSource{reader, 0})
+	}
+
+	if needSpeaker {
+		for i, path := range append([]string{p.config.SpeakerFile}, p.config.ExtraSpeakerFiles...) {
+			reader, err := in.openFile(p, path, channelName("speaker", i), p.config.SpeakerFormat, p.config.SpeakerRate)
+			if err != nil {
+				in.Close()
+				return nil, err
+			}
+			in.speakers = append(in.speakers, channelSource{reader, 0})
+		}
+	}
+
+	return in, nil
+}
+
+// streamInputs wraps caller-provided input streams (one mic and speaker channel each)
+func (p *Processor) streamInputs(streams types.Streams) (*audioInputs, error) {
+	in := &audioInputs{}
+	needSpeaker := p.needsSpeakerFile() || p.config.StereoOutput
+
+	if streams.Input != nil {
+		if err := in.addInterleaved(p, streams.Input, "input stream", "input stream", needSpeaker); err != nil {
+			in.Close()
+			return nil, err
+		}
+		return in, nil
+	}
+
+	reader, err := in.addMono(p, streams.Mic, "mic stream", "mic", p.config.MicFormat, p.config.MicRate)
+	if err != nil {
+		in.Close()
+		return nil, fmt.Errorf("failed to read mic stream: %w", err)
+	}
+	in.mics = append(in.mics, channelSource{reader, 0})
+
+	if needSpeaker {
+		reader, err := in.addMono(p, streams.Speaker, "speaker stream", "speaker", p.config.SpeakerFormat, p.config.SpeakerRate)
+		if err != nil {
+			in.Close()
+			return nil, fmt.Errorf("failed to read speaker stream: %w", err)
+		}
+		in.speakers = append(in.speakers, channelSource{reader, 0})
+	}
+	return in, nil
+}
+
+// addInterleaved adds an interleaved input carrying the configured mic and speaker channels
+// path names the stream in header errors, desc in the remaining ones
+func (in *audioInputs) addInterleaved(p *Processor, r io.Reader, path, desc string, needSpeaker bool) error {
+	reader, err := p.newAudioReader(r, path, p.config.MicFormat, p.config.InputChannels, p.config.MicRate)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", desc, err)
+	}
+	in.readers = append(in.readers, namedReader{reader, "input"})
+
+	micChannels := append([]int{p.config.MicChannel}, p.config.ExtraMicChannels...)
+	var speakerChannels []int
+	if needSpeaker {
+		speakerChannels = append([]int{p.config.SpeakerChannel}, p.config.ExtraSpeakerChannels...)
+	}
+	for _, ch := range append(append([]int{}, micChannels...), speakerChannels...) {
+		if ch < 0 || ch >= reader.channels {
+			return fmt.Errorf("%s has %d channels, channel %d is out of range", desc, reader.channels, ch)
+		}
+	}
+	for _, ch := range micChannels {
+		in.mics = append(in.mics, channelSource{reader, ch})
+	}
+	for _, ch := range speakerChannels {
+		in.speakers = append(in.speakers, channelSource{reader, ch})
+	}
+	return nil
+}
+
+// openFile opens a mono input file and adds its reader
+func (in *audioInputs) openFile(p *Processor, path, name string, format types.SampleFormat, rate int) (*audioReader, error) {
+	file, err := in.open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s file: %w", name, err)
+	}
+
+	reader, err := in.addMono(p, file, path, name, format, rate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %w", name, err)
+	}
+	return reader, nil
+}
+
+// open opens an input file, or returns standard input for StdioPath
+// Opened files are closed by Close, standard input is left open
+func (in *audioInputs) open(path string) (*os.File, error) {
+	if path == types.StdioPath {
+		return os.Stdin, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	in.files = append(in.files, file)
+	return file, nil
+}
+
+// addMono adds a mono input; path names the stream in header errors
+func (in *audioInputs) addMono(p *Processor, r io.Reader, path, name string, format types.SampleFormat, rate int) (*audioReader, error) {
+	reader, err := p.newAudioReader(r, path, format, 1, rate)
+	if err != nil {
+		return nil, err
+	}
+	if reader.channels != 1 {
+		return nil, fmt.Errorf("%s: expected a mono WAV file (got %d channels), use -input for interleaved files", path, reader.channels)
+	}
+	in.readers = append(in.readers, namedReader{reader, name})
+	return reader, nil
+}
+
+// channelName names the input of a channel in errors ("mic", "mic 1", ...)
+func channelName(stream string, index int) string {
+	if index == 0 {
+		return stream
+	}
+	return fmt.Sprintf("%s %d", stream, index)
+}
+
+// read reads the next frame of every input
+// Partial frames at end of input are padded with silence; io.EOF is returned
+// once any input is exhausted
+func (in *audioInputs) read() error {
+	for _, r := range in.readers {
+		if err := r.reader.readFrame(); err != nil {
+			return fmt.Errorf("error reading %s: %w", r.name, err)
+		}
+	}
+	return nil
+}
+
+// readFrame reads the next mic and speaker frames of the first mic and speaker channel
+func (in *audioInputs) readFrame(micPcmFrame, speakerPcmFrame []int16) error {
+	if err := in.read(); err != nil {
+		return err
+	}
+	in.mics[0].reader.channel(in.mics[0].channel, micPcmFrame)
+	if len(in.speakers) > 0 {
+		in.speakers[0].reader.channel(in.speakers[0].channel, speakerPcmFrame)
+	}
+	return nil
+}
+
+// readChannels reads the next frame of every mic and speaker channel
+func (in *audioInputs) readChannels(micFrames, speakerFrames [][]int16) error {
+	if err := in.read(); err != nil {
+		return err
+	}
+	for i, src := range in.mics {
+		src.reader.channel(src.channel, micFrames[i])
+	}
+	for i, src := range in.speakers {
+		src.reader.channel(src.channel, speakerFrames[i])
+	}
+	return nil
+}
+
+// Close closes all input files and releases resamplers
+func (in *audioInputs) Close() error {
+	for _, r := range in.readers {
+		if r.reader.converter != nil {
+			r.reader.converter.Destroy()
+		}
+	}
+
+	var firstErr error
+	for _, file := range in.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// newAudioReader wraps an input stream, parsing the RIFF/WAVE header when present
+// Headerless streams are read as raw samples in the given format, channel count and rate
+// (a rate of 0 means the processing rate); WAV streams use the values from their header,
+// so rawChannels does not apply to them
+func (p *Processor) newAudioReader(r io.Reader, path string, rawFormat types.SampleFormat, rawChannels, rate int) (*audioReader, error) {
+	br := bufio.NewReader(r)
+
+	if !audio.IsWAV(br) {
+		if audio.HasWAVExtension(path) {
+			return nil, fmt.Errorf("%s: missing RIFF/WAVE header", path)
+		}
+		codec, err := lookupCodec(rawFormat)
+		if err != nil {
+			return nil, err
+		}
+		if rawChannels <= 0 {
+			rawChannels = 1
+		}
+		if rate <= 0 {
+			rate = p.config.SampleRate
+		}
+		return p.newFrameReader(br, codec, rawChannels, rate)
+	}
+
+	header, err := audio.ReadWAVHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	codec, err := audio.LookupWAVCodec(header.AudioFormat, header.BitsPerSample)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if header.NumChannels == 0 {
+		return nil, fmt.Errorf("%s: WAV header has no channels", path)
+	}
+	if header.SampleRate == 0 {
+		return nil, fmt.Errorf("%s: WAV header has no sample rate", path)
+	}
+	if rate > 0 && int(header.SampleRate) != rate {
+		return nil, fmt.Errorf("%s: sample rate %d Hz does not match the configured stream rate %d Hz", path, header.SampleRate, rate)
+	}
+
+	var data io.Reader = br
+	if header.DataSize != 0 && header.DataSize != 0xFFFFFFFF {
+		// Stop at the end of the data chunk, trailing chunks are not audio
+		data = io.LimitReader(br, int64(header.DataSize))
+	}
+	return p.newFrameReader(data, codec, int(header.NumChannels), int(header.SampleRate))
+}
+
+// newFrameReader allocates the frame buffers of an audioReader and, if the stream
+// rate differs from the processing rate, its resampler
+func (p *Processor) newFrameReader(r io.Reader, codec audio.Codec, channels, rate int) (*audioReader, error) {
+	ar := &audioReader{
+		r:        r,
+		codec:    codec,
+		channels: channels,
+		rate:     rate,
+		pcm:      make([]int16, p.config.FrameSize*channels),
+	}
+
+	if rate == p.config.SampleRate {
+		ar.buf = make([]byte, p.config.FrameSize*channels*codec.BytesPerSample())
+		return ar, nil
+	}
+
+	converter, err := newRateConverter(channels, rate, p.config.SampleRate, p.config.ResampleQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize resampler: %w", err)
+	}
+	blockSize := max(p.config.FrameSize*rate/p.config.SampleRate, 1)
+	ar.converter = converter
+	ar.buf = make([]byte, blockSize*channels*codec.BytesPerSample())
+	ar.block = make([]int16, blockSize*channels)
+	return ar, nil
+}
+
+// newAudioWriter wraps an output stream, writing a WAV header if wav is set
+func (p *Processor) newAudioWriter(w io.Writer, wav bool, channels int) (*audioWriter, error) {
+	codec, err := lookupCodec(p.config.OutputFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := p.config.OutputRate
+	if rate <= 0 {
+		rate = p.config.SampleRate
+	}
+
+	aw := &audioWriter{
+		w:        w,
+		codec:    codec,
+		channels: channels,
+		rate:     rate,
+		pcm:      make([]int16, p.config.FrameSize*channels),
+		buf:      make([]byte, p.config.FrameSize*channels*codec.BytesPerSample()),
+	}
+
+	if rate != p.config.SampleRate {
+		aw.converter, err = newRateConverter(channels, p.config.SampleRate, rate, p.config.ResampleQuality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize resampler: %w", err)
+		}
+	}
+
+	if wav {
+		wavCodec, ok := codec.(audio.WAVCodec)
+		if !ok {
+			aw.destroy()
+			return nil, fmt.Errorf("sample format %s cannot be stored in a WAV file", codec.Name())
+		}
+		tag, _ := wavCodec.WAVFormat()
+		wavWriter, err := audio.NewWAVWriter(w, tag, rate, channels)
+		if err != nil {
+			aw.destroy()
+			return nil, err
+		}
+		aw.w = wavWriter
+		aw.wav = wavWriter
+	}
+	return aw, nil
+}
+
+// readFrame reads and decodes one interleaved frame at the processing rate
+// A partial frame at end of input is padded with silence
+func (ar *audioReader) readFrame() error {
+	if ar.converter == nil {
+		if _, err := ar.readBlock(ar.pcm); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	for len(ar.ready) < len(ar.pcm) && !ar.eof {
+		samples, err := ar.readBlock(ar.block)
+		if err == io.EOF {
+			ar.eof = true
+			ar.ready, err = ar.converter.flush(ar.ready)
+		} else if err == nil {
+			ar.ready, err = ar.converter.convert(ar.ready, ar.block[:samples])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(ar.ready) == 0 {
+		return io.EOF
+	}
+	n := copy(ar.pcm, ar.ready)
+	clear(ar.pcm[n:])
+	ar.ready = ar.ready[:copy(ar.ready, ar.ready[n:])]
+	return nil
+}
+
+// readBlock reads one block of encoded samples at the stream rate and decodes it into dst
+// A partial block is padded with the codec's encoded silence; the returned count covers
+// only the samples (all channels) that were actually read
+func (ar *audioReader) readBlock(dst []int16) (int, error) {
+	n, err := io.ReadFull(ar.r, ar.buf)
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	if n < len(ar.buf) {
+		silence := ar.codec.Silence()
+		for i := n; i < len(ar.buf); i++ {
+			ar.buf[i] = silence[i%len(silence)]
+		}
+	}
+
+	ar.codec.Decode(ar.buf, dst)
+
+	frameBytes := ar.channels * ar.codec.BytesPerSample()
+	return (n + frameBytes - 1) / frameBytes * ar.channels, nil
+}
+
+// channel copies one channel of the last decoded frame into dst
+func (ar *audioReader) channel(ch int, dst []int16) {
+	if ar.channels == 1 {
+		copy(dst, ar.pcm)
+		return
+	}
+	for i := range dst {
+		dst[i] = ar.pcm[i*ar.channels+ch]
+	}
+}
+
+// writeFrame interleaves one PCM16 frame per channel, encodes and writes it to the output
+// The last frame of the output may be shorter
+func (aw *audioWriter) writeFrame(channels ...[]int16) error {
+	pcm := channels[0]
+	if aw.channels > 1 {
+		for ch, frame := range channels {
+			for i, sample := range frame {
+				aw.pcm[i*aw.channels+ch] = sample
+			}
+		}
+		pcm = aw.pcm[:len(channels[0])*aw.channels]
+	}
+	return aw.writeInterleaved(pcm)
+}
+
+// writeInterleaved resamples and writes one interleaved frame
+func (aw *audioWriter) writeInterleaved(pcm []int16) error {
+	if aw.converter != nil {
+		var err error
+		aw.converted, err = aw.converter.convert(aw.converted[:0], pcm)
+		if err != nil {
+			return err
+		}
+		pcm = aw.converted
+	}
+	return aw.write(pcm)
+}
+
+// write encodes interleaved samples and writes them to the output
+func (aw *audioWriter) write(pcm []int16) error {
+	size := len(pcm) * aw.codec.BytesPerSample()
+	if cap(aw.buf) < size {
+		aw.buf = make([]byte, size)
+	}
+	aw.codec.Encode(pcm, aw.buf[:size])
+	_, err := aw.w.Write(aw.buf[:size])
+	return err
+}
+
+// Close drains the resampler and finalizes the WAV header if the output is a WAV file
+func (aw *audioWriter) Close() error {
+	if aw.converter != nil {
+		var err error
+		aw.converted, err = aw.converter.flush(aw.converted[:0])
+		if err != nil {
+			return err
+		}
+		if err := aw.write(aw.converted); err != nil {
+			return err
+		}
+	}
+	aw.destroy()
+
+	if aw.wav != nil {
+		return aw.wav.Close()
+	}
+	return nil
+}
+
+// destroy releases the output resampler
+func (aw *audioWriter) destroy() {
+	if aw.converter != nil {
+		aw.converter.Destroy()
+		aw.converter = nil
+	}
+}
+
+// lookupCodec returns the registered codec for a sample format (A-law if unset)
+func lookupCodec(format types.SampleFormat) (audio.Codec, error) {
+	if format == "" {
+		format = types.FormatALaw
+	}
+	return audio.LookupCodec(string(format))
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"open_tool_speex/internal/vad"
+	"open_tool_speex/pkg/speex"
+)
+
+// vadTimeline collects per-frame voice activity and writes the timeline and speech segment files
+type vadTimeline struct {
+	timeline  *vad.Timeline
+	csvPath   string
+	jsonPath  string
+	labelPath string
+}
+
+// newVADTimeline creates the timeline, or returns nil if no VAD output is requested
+func (p *Processor) newVADTimeline() *vadTimeline {
+	if p.config.VADCSV == "" && p.config.VADJSON == "" && p.config.VADLabels == "" {
+		return nil
+	}
+	return &vadTimeline{
+		timeline:  vad.NewTimeline(p.config.SampleRate, p.config.FrameSize),
+		csvPath:   p.config.VADCSV,
+		jsonPath:  p.config.VADJSON,
+		labelPath: p.config.VADLabels,
+	}
+}
+
+// add records the voice activity of the next frame
+func (vt *vadTimeline) add(result speex.VADResult) {
+	vt.timeline.Add(result.Speech, result.Probability)
+}
+
+// finish writes the requested files and prints the speech total
+func (vt *vadTimeline) finish() error {
+	segments := vt.timeline.Segments()
+	speech := 0.0
+	for _, s := range segments {
+		speech += s.EndSec - s.StartSec
+	}
+	fmt.Fprintf(os.Stderr, "Speech: %.1f seconds in %d segments\n", speech, len(segments))
+
+	outputs := []struct {
+		path  string
+		what  string
+		write func(io.Writer) error
+	}{
+		{vt.csvPath, "VAD CSV", vt.timeline.WriteCSV},
+		{vt.jsonPath, "VAD JSON", vt.timeline.WriteJSON},
+		{vt.labelPath, "VAD label", vt.timeline.WriteLabels},
+	}
+	for _, o := range outputs {
+		if o.path == "" {
+			continue
+		}
+		if err := writeFile(o.path, o.write); err != nil {
+			return fmt.Errorf("failed to write %s file: %w", o.what, err)
+		}
+	}
+	return nil
+}
+
+// writeFile creates path and fills it with write
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := write(file); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,77 @@
+package vad
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Edit maps a range of kept source frames [SourceStart, SourceEnd) to its position in the output
+type Edit struct {
+	SourceStart int
+	SourceEnd   int
+	OutputStart int
+}
+
+// EditList records which frames are kept when frames without speech are dropped
+type EditList struct {
+	sampleRate int
+	frameSize  int
+	edits      []Edit
+	frames     int
+	kept       int
+	open       bool // the last frame was kept and extends the last edit
+}
+
+// NewEditList creates an empty edit list for frames of frameSize samples
+func NewEditList(sampleRate, frameSize int) *EditList {
+	return &EditList{
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+	}
+}
+
+// Add records whether the next source frame is kept
+func (el *EditList) Add(keep bool) {
+	if keep {
+		if el.open {
+			el.edits[len(el.edits)-1].SourceEnd++
+		} else {
+			el.edits = append(el.edits, Edit{SourceStart: el.frames, SourceEnd: el.frames + 1, OutputStart: el.kept})
+		}
+		el.kept++
+	}
+	el.open = keep
+	el.frames++
+}
+
+// Edits returns the kept ranges in source order
+func (el *EditList) Edits() []Edit {
+	return el.edits
+}
+
+// Frames returns the number of source frames and how many were kept
+func (el *EditList) Frames() (total, kept int) {
+	return el.frames, el.kept
+}
+
+// WriteCSV writes one row per kept range with source and output times in seconds
+func (el *EditList) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"source_start_sec", "source_end_sec", "output_start_sec", "duration_sec"})
+	for _, e := range el.edits {
+		cw.Write([]string{
+			el.formatTime(e.SourceStart),
+			el.formatTime(e.SourceEnd),
+			el.formatTime(e.OutputStart),
+			el.formatTime(e.SourceEnd - e.SourceStart),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatTime formats a frame count as seconds
+func (el *EditList) formatTime(frames int) string {
+	return strconv.FormatFloat(float64(frames*el.frameSize)/float64(el.sampleRate), 'f', 3, 64)
+}
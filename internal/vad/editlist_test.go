@@ -0,0 +1,39 @@
+package vad
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEditList(t *testing.T) {
+	el := NewEditList(16000, 320)
+	for _, keep := range []bool{false, true, true, false, false, true} {
+		el.Add(keep)
+	}
+
+	expected := []Edit{
+		{SourceStart: 1, SourceEnd: 3, OutputStart: 0},
+		{SourceStart: 5, SourceEnd: 6, OutputStart: 2},
+	}
+	edits := el.Edits()
+	if len(edits) != len(expected) {
+		t.Fatalf("Edits() = %+v, expected %+v", edits, expected)
+	}
+	for i := range edits {
+		if edits[i] != expected[i] {
+			t.Errorf("Edits()[%d] = %+v, expected %+v", i, edits[i], expected[i])
+		}
+	}
+	if total, kept := el.Frames(); total != 6 || kept != 3 {
+		t.Errorf("Frames() = %d, %d, expected 6, 3", total, kept)
+	}
+
+	var out bytes.Buffer
+	if err := el.WriteCSV(&out); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "source_start_sec,source_end_sec,output_start_sec,duration_sec\n0.020,0.060,0.000,0.040\n0.100,0.120,0.040,0.020\n"
+	if out.String() != want {
+		t.Errorf("WriteCSV() = %q, expected %q", out.String(), want)
+	}
+}
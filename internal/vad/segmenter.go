@@ -0,0 +1,117 @@
+package vad
+
+// Span is a run of consecutive frames [Start, End) assigned to one speech segment
+// Segment numbers start at 1; 0 marks frames outside any segment
+type Span struct {
+	Start   int
+	End     int
+	Segment int
+}
+
+// Segmenter groups per-frame VAD decisions into padded speech segments as they arrive
+// Frames are assigned in order, each once, as soon as their segment is known; the
+// assignment lags the input by at most the padding, gap and minimum length
+type Segmenter struct {
+	pad    int // frames of padding before and after the speech
+	minLen int // shortest kept segment in frames (speech span without padding)
+	maxGap int // longest silence in frames kept inside a segment
+
+	frames    int // frames added
+	next      int // first unassigned frame
+	active    bool
+	confirmed bool
+	start     int // first speech frame of the current segment
+	last      int // last speech frame of the current segment
+	segments  int
+}
+
+// NewSegmenter creates a segmenter; all lengths are in frames
+func NewSegmenter(pad, minLen, maxGap int) *Segmenter {
+	return &Segmenter{
+		pad:    max(pad, 0),
+		minLen: max(minLen, 1),
+		maxGap: max(maxGap, 0),
+	}
+}
+
+// Add takes the VAD decision of the next frame and returns the frames assigned by it
+func (s *Segmenter) Add(speech bool) []Span {
+	var spans []Span
+	f := s.frames
+	s.frames++
+
+	if s.active {
+		gap := f - s.last // silent frames since the last speech, including this one
+		if speech {
+			gap--
+		}
+		// The silence outlasted the gap: the segment is over once its end padding has arrived
+		if gap > s.maxGap && (speech || f-s.last >= s.pad) {
+			limit := s.frames
+			if speech {
+				limit = f // the new speech frame starts the next segment
+			}
+			spans = s.assign(spans, limit)
+			s.active = false
+		}
+	}
+
+	if speech {
+		if !s.active {
+			s.active = true
+			s.confirmed = false
+			s.start = f
+		}
+		s.last = f
+		if !s.confirmed && s.last+1-s.start >= s.minLen {
+			s.confirmed = true
+			s.segments++
+		}
+	}
+
+	return s.assign(spans, s.frames)
+}
+
+// Flush assigns the remaining frames at the end of the stream
+func (s *Segmenter) Flush() []Span {
+	var spans []Span
+	if s.active {
+		spans = s.assign(spans, s.frames)
+		s.active = false
+	}
+	return s.closeSpan(spans, s.frames)
+}
+
+// Segments returns the number of segments started so far
+func (s *Segmenter) Segments() int {
+	return s.segments
+}
+
+// assign assigns frames up to limit according to the current state
+func (s *Segmenter) assign(spans []Span, limit int) []Span {
+	switch {
+	case !s.active:
+		// Silence may still become lead-in padding of a later segment
+		return s.closeSpan(spans, limit-s.pad)
+	case !s.confirmed:
+		return s.closeSpan(spans, s.start-s.pad)
+	default:
+		spans = s.closeSpan(spans, s.start-s.pad)
+		return s.appendSpan(spans, min(limit, s.last+1+s.pad), s.segments)
+	}
+}
+
+// closeSpan assigns frames before end to no segment
+func (s *Segmenter) closeSpan(spans []Span, end int) []Span {
+	return s.appendSpan(spans, end, 0)
+}
+
+// appendSpan assigns the unassigned frames before end to segment
+func (s *Segmenter) appendSpan(spans []Span, end, segment int) []Span {
+	if end <= s.next {
+		return spans
+	}
+	spans = append(spans, Span{Start: s.next, End: end, Segment: segment})
+	s.next = end
+	return spans
+}
@@ -0,0 +1,110 @@
+package vad
+
+import (
+	"strconv"
+	"testing"
+)
+
+// segment runs a pattern of decisions ('#' speech, '.' silence) through a segmenter and
+// returns the segment of every frame ('.' for none)
+func segment(t *testing.T, s *Segmenter, pattern string) string {
+	t.Helper()
+	var spans []Span
+	for _, c := range pattern {
+		spans = append(spans, s.Add(c == '#')...)
+	}
+	spans = append(spans, s.Flush()...)
+
+	out := ""
+	next := 0
+	for _, span := range spans {
+		if span.Start != next || span.End <= span.Start {
+			t.Fatalf("span %+v does not continue at frame %d", span, next)
+		}
+		next = span.End
+		for i := span.Start; i < span.End; i++ {
+			if span.Segment == 0 {
+				out += "."
+			} else {
+				out += strconv.Itoa(span.Segment)
+			}
+		}
+	}
+	if next != len(pattern) {
+		t.Fatalf("assigned %d frames, want %d", next, len(pattern))
+	}
+	return out
+}
+
+func TestSegmenter(t *testing.T) {
+	tests := []struct {
+		name                string
+		pad, minLen, maxGap int
+		pattern             string
+		expected            string
+	}{
+		{
+			name: "silence",
+			pad:  1, minLen: 1, maxGap: 0,
+			pattern:  "......",
+			expected: "......",
+		},
+		{
+			name: "padding",
+			pad:  2, minLen: 1, maxGap: 0,
+			pattern:  "....##.....",
+			expected: "..111111...",
+		},
+		{
+			name: "padding clipped at the edges",
+			pad:  2, minLen: 1, maxGap: 0,
+			pattern:  "#.....#",
+			expected: "111.222",
+		},
+		{
+			name: "short gap merged",
+			pad:  0, minLen: 1, maxGap: 2,
+			pattern:  ".##..##...##.",
+			expected: ".111111...22.",
+		},
+		{
+			name: "short segment dropped",
+			pad:  1, minLen: 3, maxGap: 0,
+			pattern:  "..##.....###..",
+			expected: "........11111.",
+		},
+		{
+			name: "padding longer than the gap",
+			pad:  3, minLen: 1, maxGap: 1,
+			pattern:  "#.....#",
+			expected: "1111222",
+		},
+		{
+			name: "speech until the end",
+			pad:  1, minLen: 2, maxGap: 0,
+			pattern:  "...###",
+			expected: "..1111",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := segment(t, NewSegmenter(tt.pad, tt.minLen, tt.maxGap), tt.pattern)
+			if got != tt.expected {
+				t.Errorf("segments of %q = %q, expected %q", tt.pattern, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSegmenter_Latency(t *testing.T) {
+	// With a confirmed segment, speech frames are assigned as soon as they arrive
+	s := NewSegmenter(0, 1, 5)
+	s.Add(false)
+	if spans := s.Add(true); len(spans) != 1 || spans[0] != (Span{Start: 1, End: 2, Segment: 1}) {
+		t.Errorf("Add(true) = %+v, expected the speech frame in segment 1", spans)
+	}
+	if s.Segments() != 1 {
+		t.Errorf("Segments() = %d, expected 1", s.Segments())
+	}
+}
@@ -0,0 +1,127 @@
+package vad
+
+// Voice activity timeline: per-frame decisions, speech segments and their export formats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Frame holds the voice activity of one frame
+type Frame struct {
+	Index       int     `json:"frame"`
+	TimeSec     float64 `json:"time_sec"`
+	Speech      bool    `json:"speech"`
+	Probability int     `json:"probability"`
+}
+
+// Segment is a run of consecutive speech frames
+type Segment struct {
+	StartSec float64 `json:"start_sec"`
+	EndSec   float64 `json:"end_sec"`
+}
+
+// Timeline collects per-frame voice activity
+type Timeline struct {
+	sampleRate int
+	frameSize  int
+	frames     []Frame
+}
+
+// NewTimeline creates an empty timeline for frames of frameSize samples
+func NewTimeline(sampleRate, frameSize int) *Timeline {
+	return &Timeline{
+		sampleRate: sampleRate,
+		frameSize:  frameSize,
+	}
+}
+
+// Add appends the voice activity of the next frame
+func (t *Timeline) Add(speech bool, probability int) {
+	index := len(t.frames)
+	t.frames = append(t.frames, Frame{
+		Index:       index,
+		TimeSec:     t.frameTime(index),
+		Speech:      speech,
+		Probability: probability,
+	})
+}
+
+// Frames returns the recorded frames
+func (t *Timeline) Frames() []Frame {
+	return t.frames
+}
+
+// Segments returns the speech segments, merging consecutive speech frames
+func (t *Timeline) Segments() []Segment {
+	var segments []Segment
+	start := -1
+	for i, f := range t.frames {
+		if f.Speech && start < 0 {
+			start = i
+		}
+		if !f.Speech && start >= 0 {
+			segments = append(segments, Segment{StartSec: t.frameTime(start), EndSec: t.frameTime(i)})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		segments = append(segments, Segment{StartSec: t.frameTime(start), EndSec: t.frameTime(len(t.frames))})
+	}
+	return segments
+}
+
+// WriteCSV writes one row per frame
+func (t *Timeline) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"frame", "time_sec", "speech", "probability"})
+	for _, f := range t.frames {
+		cw.Write([]string{
+			strconv.Itoa(f.Index),
+			strconv.FormatFloat(f.TimeSec, 'f', 3, 64),
+			strconv.FormatBool(f.Speech),
+			strconv.Itoa(f.Probability),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the frames and speech segments as indented JSON
+func (t *Timeline) WriteJSON(w io.Writer) error {
+	segments := t.Segments()
+	if segments == nil {
+		segments = []Segment{}
+	}
+	frames := t.frames
+	if frames == nil {
+		frames = []Frame{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		SampleRate int       `json:"sample_rate"`
+		FrameSize  int       `json:"frame_size"`
+		Segments   []Segment `json:"segments"`
+		Frames     []Frame   `json:"frames"`
+	}{t.sampleRate, t.frameSize, segments, frames})
+}
+
+// WriteLabels writes the speech segments as an Audacity label track
+func (t *Timeline) WriteLabels(w io.Writer) error {
+	for i, s := range t.Segments() {
+		if _, err := fmt.Fprintf(w, "%.6f\t%.6f\tspeech %d\n", s.StartSec, s.EndSec, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameTime returns the start time of a frame in seconds
+func (t *Timeline) frameTime(index int) float64 {
+	return float64(index*t.frameSize) / float64(t.sampleRate)
+}
@@ -0,0 +1,82 @@
+package vad
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTimeline_Segments(t *testing.T) {
+	tests := []struct {
+		name     string
+		speech   []bool
+		expected []Segment
+	}{
+		{name: "empty", speech: nil, expected: nil},
+		{name: "silence", speech: []bool{false, false}, expected: nil},
+		{name: "single segment", speech: []bool{false, true, true, false}, expected: []Segment{{0.02, 0.06}}},
+		{name: "speech at the end", speech: []bool{true, false, true}, expected: []Segment{{0, 0.02}, {0.04, 0.06}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := NewTimeline(16000, 320)
+			for _, s := range tt.speech {
+				tl.Add(s, 0)
+			}
+			got := tl.Segments()
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Segments() = %v, expected %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Segments()[%d] = %v, expected %v", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTimeline_Writers(t *testing.T) {
+	tl := NewTimeline(8000, 80)
+	tl.Add(false, 5)
+	tl.Add(true, 95)
+	tl.Add(true, 90)
+	tl.Add(false, 10)
+
+	var csvOut bytes.Buffer
+	if err := tl.WriteCSV(&csvOut); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	wantCSV := "frame,time_sec,speech,probability\n0,0.000,false,5\n1,0.010,true,95\n2,0.020,true,90\n3,0.030,false,10\n"
+	if csvOut.String() != wantCSV {
+		t.Errorf("WriteCSV() = %q, expected %q", csvOut.String(), wantCSV)
+	}
+
+	var labels bytes.Buffer
+	if err := tl.WriteLabels(&labels); err != nil {
+		t.Fatalf("WriteLabels() error = %v", err)
+	}
+	if want := "0.010000\t0.030000\tspeech 1\n"; labels.String() != want {
+		t.Errorf("WriteLabels() = %q, expected %q", labels.String(), want)
+	}
+
+	var jsonOut bytes.Buffer
+	if err := tl.WriteJSON(&jsonOut); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	var decoded struct {
+		Segments []Segment `json:"segments"`
+		Frames   []Frame   `json:"frames"`
+	}
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() produced invalid JSON: %v", err)
+	}
+	if len(decoded.Frames) != 4 || len(decoded.Segments) != 1 || decoded.Frames[1].Probability != 95 {
+		t.Errorf("WriteJSON() = %s", jsonOut.String())
+	}
+	if !strings.Contains(jsonOut.String(), `"sample_rate": 8000`) {
+		t.Errorf("WriteJSON() is missing the sample rate: %s", jsonOut.String())
+	}
+}
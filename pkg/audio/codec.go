@@ -0,0 +1,201 @@
+package audio
+
+// Sample format codecs and the registry used to look them up by name
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec converts between an encoded sample format and 16-bit linear PCM
+type Codec interface {
+	// Name returns the canonical registry name of the format
+	Name() string
+	// BytesPerSample returns the encoded size of a single mono sample
+	BytesPerSample() int
+	// Decode converts encoded samples to PCM16 (up to the shorter of both buffers)
+	Decode(src []byte, dst []int16)
+	// Encode converts PCM16 samples to encoded bytes (up to the shorter of both buffers)
+	Encode(src []int16, dst []byte)
+	// Silence returns the encoding of a single silent sample
+	Silence() []byte
+}
+
+// WAVCodec is implemented by codecs that can be stored in a WAV data chunk
+type WAVCodec interface {
+	Codec
+	// WAVFormat returns the WAV format tag and bits per sample of the encoding
+	WAVFormat() (tag uint16, bitsPerSample uint16)
+}
+
+var (
+	codecMu      sync.RWMutex
+	codecs       = make(map[string]Codec)
+	codecAliases = make(map[string]string)
+)
+
+// RegisterCodec adds a codec to the registry under its name and optional aliases
+// Registering a name twice replaces the previous codec
+func RegisterCodec(codec Codec, aliases ...string) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	name := strings.ToLower(codec.Name())
+	codecs[name] = codec
+	for _, alias := range aliases {
+		codecAliases[strings.ToLower(alias)] = name
+	}
+}
+
+// LookupCodec returns the codec registered under a name or alias (case-insensitive)
+func LookupCodec(name string) (Codec, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	key := strings.ToLower(name)
+	if canonical, ok := codecAliases[key]; ok {
+		key = canonical
+	}
+	codec, ok := codecs[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown sample format: %q (want one of %s)", name, strings.Join(codecNamesLocked(), ", "))
+	}
+	return codec, nil
+}
+
+// LookupWAVCodec returns the registered codec matching a WAV format tag and sample width
+func LookupWAVCodec(tag, bitsPerSample uint16) (Codec, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	for _, name := range codecNamesLocked() {
+		if wc, ok := codecs[name].(WAVCodec); ok {
+			if t, bits := wc.WAVFormat(); t == tag && bits == bitsPerSample {
+				return wc, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported WAV format (tag 0x%04X, %d bits)", tag, bitsPerSample)
+}
+
+// CodecNames returns the sorted names of all registered codecs
+func CodecNames() []string {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	return codecNamesLocked()
+}
+
+func codecNamesLocked() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterCodec(alawCodec{}, "pcma")
+	RegisterCodec(ulawCodec{}, "ulaw", "pcmu")
+	RegisterCodec(s16leCodec{}, "s16le", "s16", "linear")
+	RegisterCodec(s16beCodec{})
+	RegisterCodec(f32leCodec{}, "float32")
+}
+
+// alawCodec implements ITU-T G.711 A-law
+type alawCodec struct{}
+
+func (alawCodec) Name() string                   { return "alaw" }
+func (alawCodec) BytesPerSample() int            { return 1 }
+func (alawCodec) Decode(src []byte, dst []int16) { AlawBufferToPCM16(src, dst) }
+func (alawCodec) Encode(src []int16, dst []byte) { PCM16BufferToAlaw(src, dst) }
+func (alawCodec) Silence() []byte                { return []byte{0xD5} }
+func (alawCodec) WAVFormat() (uint16, uint16)    { return WAVFormatALaw, 8 }
+
+// ulawCodec implements ITU-T G.711 mu-law
+type ulawCodec struct{}
+
+func (ulawCodec) Name() string                   { return "mulaw" }
+func (ulawCodec) BytesPerSample() int            { return 1 }
+func (ulawCodec) Decode(src []byte, dst []int16) { UlawBufferToPCM16(src, dst) }
+func (ulawCodec) Encode(src []int16, dst []byte) { PCM16BufferToUlaw(src, dst) }
+func (ulawCodec) Silence() []byte                { return []byte{0xFF} }
+func (ulawCodec) WAVFormat() (uint16, uint16)    { return WAVFormatMuLaw, 8 }
+
+// s16leCodec implements signed 16-bit little-endian linear PCM
+type s16leCodec struct{}
+
+func (s16leCodec) Name() string                { return "pcm16" }
+func (s16leCodec) BytesPerSample() int         { return 2 }
+func (s16leCodec) Silence() []byte             { return []byte{0, 0} }
+func (s16leCodec) WAVFormat() (uint16, uint16) { return WAVFormatPCM, 16 }
+
+func (s16leCodec) Decode(src []byte, dst []int16) {
+	for i := 0; 2*i+1 < len(src) && i < len(dst); i++ {
+		dst[i] = int16(binary.LittleEndian.Uint16(src[2*i:]))
+	}
+}
+
+func (s16leCodec) Encode(src []int16, dst []byte) {
+	for i := 0; i < len(src) && 2*i+1 < len(dst); i++ {
+		binary.LittleEndian.PutUint16(dst[2*i:], uint16(src[i]))
+	}
+}
+
+// s16beCodec implements signed 16-bit big-endian linear PCM
+type s16beCodec struct{}
+
+func (s16beCodec) Name() string        { return "s16be" }
+func (s16beCodec) BytesPerSample() int { return 2 }
+func (s16beCodec) Silence() []byte     { return []byte{0, 0} }
+
+func (s16beCodec) Decode(src []byte, dst []int16) {
+	for i := 0; 2*i+1 < len(src) && i < len(dst); i++ {
+		dst[i] = int16(binary.BigEndian.Uint16(src[2*i:]))
+	}
+}
+
+func (s16beCodec) Encode(src []int16, dst []byte) {
+	for i := 0; i < len(src) && 2*i+1 < len(dst); i++ {
+		binary.BigEndian.PutUint16(dst[2*i:], uint16(src[i]))
+	}
+}
+
+// f32leCodec implements 32-bit little-endian IEEE float samples in [-1, 1)
+type f32leCodec struct{}
+
+func (f32leCodec) Name() string                { return "f32le" }
+func (f32leCodec) BytesPerSample() int         { return 4 }
+func (f32leCodec) Silence() []byte             { return []byte{0, 0, 0, 0} }
+func (f32leCodec) WAVFormat() (uint16, uint16) { return WAVFormatIEEEFloat, 32 }
+
+func (f32leCodec) Decode(src []byte, dst []int16) {
+	for i := 0; 4*i+3 < len(src) && i < len(dst); i++ {
+		dst[i] = Float32ToPCM16(math.Float32frombits(binary.LittleEndian.Uint32(src[4*i:])))
+	}
+}
+
+func (f32leCodec) Encode(src []int16, dst []byte) {
+	for i := 0; i < len(src) && 4*i+3 < len(dst); i++ {
+		binary.LittleEndian.PutUint32(dst[4*i:], math.Float32bits(float32(src[i])/32768))
+	}
+}
+
+// Float32ToPCM16 converts a float sample in [-1, 1) to PCM16 with rounding and clipping
+func Float32ToPCM16(v float32) int16 {
+	scaled := math.Round(float64(v) * 32768)
+	if math.IsNaN(scaled) {
+		return 0
+	}
+	if scaled > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if scaled < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(scaled)
+}
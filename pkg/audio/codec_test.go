@@ -0,0 +1,170 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLookupCodec(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "alaw", input: "alaw", expected: "alaw"},
+		{name: "alaw alias", input: "PCMA", expected: "alaw"},
+		{name: "mulaw", input: "mulaw", expected: "mulaw"},
+		{name: "mulaw alias", input: "ulaw", expected: "mulaw"},
+		{name: "pcm16", input: "pcm16", expected: "pcm16"},
+		{name: "pcm16 alias", input: "s16le", expected: "pcm16"},
+		{name: "s16be", input: "s16be", expected: "s16be"},
+		{name: "f32le", input: "f32le", expected: "f32le"},
+		{name: "unknown", input: "mp3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := LookupCodec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupCodec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && codec.Name() != tt.expected {
+				t.Errorf("LookupCodec(%q) = %s, expected %s", tt.input, codec.Name(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookupWAVCodec(t *testing.T) {
+	tests := []struct {
+		tag      uint16
+		bits     uint16
+		expected string
+	}{
+		{tag: WAVFormatPCM, bits: 16, expected: "pcm16"},
+		{tag: WAVFormatIEEEFloat, bits: 32, expected: "f32le"},
+		{tag: WAVFormatALaw, bits: 8, expected: "alaw"},
+		{tag: WAVFormatMuLaw, bits: 8, expected: "mulaw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			codec, err := LookupWAVCodec(tt.tag, tt.bits)
+			if err != nil {
+				t.Fatalf("LookupWAVCodec(0x%04X, %d) error = %v", tt.tag, tt.bits, err)
+			}
+			if codec.Name() != tt.expected {
+				t.Errorf("LookupWAVCodec(0x%04X, %d) = %s, expected %s", tt.tag, tt.bits, codec.Name(), tt.expected)
+			}
+		})
+	}
+
+	if _, err := LookupWAVCodec(WAVFormatPCM, 24); err == nil {
+		t.Errorf("LookupWAVCodec() expected error for 24-bit PCM")
+	}
+}
+
+func TestCodecSilence(t *testing.T) {
+	for _, name := range CodecNames() {
+		t.Run(name, func(t *testing.T) {
+			codec, _ := LookupCodec(name)
+			silence := codec.Silence()
+			if len(silence) != codec.BytesPerSample() {
+				t.Fatalf("Silence() length = %d, want %d", len(silence), codec.BytesPerSample())
+			}
+			pcm := make([]int16, 1)
+			pcm[0] = 1234
+			codec.Decode(silence, pcm)
+			// A-law has no exact zero, its silence code decodes to +8
+			if abs(pcm[0]) > 8 {
+				t.Errorf("Silence() decodes to %d, want ~0", pcm[0])
+			}
+		})
+	}
+}
+
+func TestLinearCodecsLossless(t *testing.T) {
+	samples := []int16{0, 1, -1, 256, -256, 32767, -32768}
+
+	for _, name := range []string{"pcm16", "s16be", "f32le"} {
+		t.Run(name, func(t *testing.T) {
+			codec, _ := LookupCodec(name)
+			data := make([]byte, len(samples)*codec.BytesPerSample())
+			back := make([]int16, len(samples))
+
+			codec.Encode(samples, data)
+			codec.Decode(data, back)
+
+			for i := range samples {
+				if back[i] != samples[i] {
+					t.Errorf("%s round trip failed at index %d: %d -> %d", name, i, samples[i], back[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEndianness(t *testing.T) {
+	le, _ := LookupCodec("pcm16")
+	be, _ := LookupCodec("s16be")
+
+	leData := make([]byte, 2)
+	beData := make([]byte, 2)
+	le.Encode([]int16{0x1234}, leData)
+	be.Encode([]int16{0x1234}, beData)
+
+	if !bytes.Equal(leData, []byte{0x34, 0x12}) || !bytes.Equal(beData, []byte{0x12, 0x34}) {
+		t.Errorf("Byte order mismatch: le=%X be=%X", leData, beData)
+	}
+}
+
+func TestFloat32ToPCM16Clipping(t *testing.T) {
+	if got := Float32ToPCM16(2.0); got != 32767 {
+		t.Errorf("Float32ToPCM16(2.0) = %d, want 32767", got)
+	}
+	if got := Float32ToPCM16(-2.0); got != -32768 {
+		t.Errorf("Float32ToPCM16(-2.0) = %d, want -32768", got)
+	}
+}
+
+// invertCodec is a test codec that stores inverted A-law bytes
+type invertCodec struct{}
+
+func (invertCodec) Name() string        { return "test-inverted" }
+func (invertCodec) BytesPerSample() int { return 1 }
+func (invertCodec) Silence() []byte     { return []byte{^byte(0xD5)} }
+
+func (invertCodec) Decode(src []byte, dst []int16) {
+	for i := 0; i < len(src) && i < len(dst); i++ {
+		dst[i] = Alaw2Linear(^src[i])
+	}
+}
+
+func (invertCodec) Encode(src []int16, dst []byte) {
+	for i := 0; i < len(src) && i < len(dst); i++ {
+		dst[i] = ^Linear2Alaw(src[i])
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec(invertCodec{}, "test-inv")
+
+	codec, err := LookupCodec("test-inv")
+	if err != nil {
+		t.Fatalf("LookupCodec() error = %v", err)
+	}
+	if codec.Name() != "test-inverted" {
+		t.Errorf("LookupCodec() = %s, want test-inverted", codec.Name())
+	}
+
+	found := false
+	for _, name := range CodecNames() {
+		if name == "test-inverted" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("CodecNames() does not include registered codec")
+	}
+}
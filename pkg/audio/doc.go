@@ -0,0 +1,7 @@
+// Package audio provides the sample format codecs (G.711 A-law and mu-law, linear PCM)
+// and WAV header reading and writing used by the processing pipeline
+//
+// Codecs are stateless and safe for concurrent use. Decode and Encode convert whole
+// buffers and never retain them. RegisterCodec is meant for package initialization;
+// registered codecs are available to every pipeline by name.
+package audio
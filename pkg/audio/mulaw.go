@@ -0,0 +1,81 @@
+package audio
+
+// mu-law encoding/decoding
+// ITU-T G.711 mu-law companding
+// Implementation based on CCITT G.711 specifications
+
+const (
+	ULAW_BIAS = 0x84 // Bias for linear code
+	ULAW_CLIP = 8159 // Maximum magnitude after scaling
+)
+
+var segUend = [8]int{0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF, 0x1FFF}
+
+// Linear2Ulaw converts a 16-bit linear PCM value to 8-bit mu-law
+// Based on CCITT G.711 specifications
+func Linear2Ulaw(pcmVal int16) uint8 {
+	mask := uint8(0)
+	val := int(pcmVal) >> 2
+
+	// Get the sign and the magnitude of the value
+	if val < 0 {
+		val = -val
+		mask = 0x7F
+	} else {
+		mask = 0xFF
+	}
+	if val > ULAW_CLIP {
+		val = ULAW_CLIP
+	}
+	val += ULAW_BIAS >> 2
+
+	// Convert the scaled magnitude to segment number
+	seg := search(val, segUend[:], 8)
+
+	// Combine the sign, segment, and quantization bits
+	if seg >= 8 { // out of range, return maximum value
+		return 0x7F ^ mask
+	}
+	uval := (seg << SEG_SHIFT) | ((val >> (seg + 1)) & QUANT_MASK)
+	return uint8(uval) ^ mask
+}
+
+// Ulaw2Linear converts a mu-law value to 16-bit linear PCM
+// Based on CCITT G.711 specifications
+func Ulaw2Linear(uVal uint8) int16 {
+	// Complement to obtain normal u-law value
+	uVal = ^uVal
+
+	// Extract and bias the quantization bits, then shift up by the segment number
+	t := ((int(uVal) & QUANT_MASK) << 3) + ULAW_BIAS
+	t <<= (int(uVal) & SEG_MASK) >> SEG_SHIFT
+
+	if (uVal & SIGN_BIT) != 0 {
+		return int16(ULAW_BIAS - t)
+	}
+	return int16(t - ULAW_BIAS)
+}
+
+// UlawToPCM16 converts mu-law sample to 16-bit PCM
+func UlawToPCM16(ulaw uint8) int16 {
+	return Ulaw2Linear(ulaw)
+}
+
+// PCM16ToUlaw converts 16-bit PCM sample to mu-law
+func PCM16ToUlaw(pcm int16) uint8 {
+	return Linear2Ulaw(pcm)
+}
+
+// UlawBufferToPCM16 converts mu-law buffer to PCM16 buffer
+func UlawBufferToPCM16(ulawData []byte, pcmData []int16) {
+	for i := 0; i < len(ulawData) && i < len(pcmData); i++ {
+		pcmData[i] = UlawToPCM16(ulawData[i])
+	}
+}
+
+// PCM16BufferToUlaw converts PCM16 buffer to mu-law buffer
+func PCM16BufferToUlaw(pcmData []int16, ulawData []byte) {
+	for i := 0; i < len(pcmData) && i < len(ulawData); i++ {
+		ulawData[i] = PCM16ToUlaw(pcmData[i])
+	}
+}
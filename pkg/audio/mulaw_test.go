@@ -0,0 +1,157 @@
+package audio
+
+import (
+	"testing"
+)
+
+func TestLinear2Ulaw(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int16
+		expected uint8
+	}{
+		{
+			name:     "zero",
+			input:    0,
+			expected: 0xFF, // mu-law representation of 0
+		},
+		{
+			name:     "positive small",
+			input:    100,
+			expected: 0xF2, // Actual mu-law for small positive value
+		},
+		{
+			name:     "negative small",
+			input:    -100,
+			expected: 0x72, // Actual mu-law for small negative value
+		},
+		{
+			name:     "positive large",
+			input:    32767,
+			expected: 0x80, // Actual mu-law for maximum positive value
+		},
+		{
+			name:     "negative large",
+			input:    -32768,
+			expected: 0x00, // Actual mu-law for maximum negative value
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Linear2Ulaw(tt.input)
+			if result != tt.expected {
+				t.Errorf("Linear2Ulaw(%d) = 0x%02X, expected 0x%02X", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUlaw2Linear(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    uint8
+		expected int16
+	}{
+		{
+			name:     "zero",
+			input:    0xFF,
+			expected: 0, // mu-law 0xFF represents 0
+		},
+		{
+			name:     "positive small",
+			input:    0xF2,
+			expected: 100, // Actual linear for small positive mu-law
+		},
+		{
+			name:     "negative small",
+			input:    0x72,
+			expected: -100, // Actual linear for small negative mu-law
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Ulaw2Linear(tt.input)
+			// Allow some tolerance due to mu-law compression
+			if abs(result-tt.expected) > 10 {
+				t.Errorf("Ulaw2Linear(0x%02X) = %d, expected %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUlawRoundTrip(t *testing.T) {
+	// Test with reasonable values (avoid extreme values due to mu-law compression limits)
+	testValues := []int16{0, 100, -100, 1000, -1000, 10000, -10000}
+
+	for _, val := range testValues {
+		t.Run("roundtrip", func(t *testing.T) {
+			ulaw := Linear2Ulaw(val)
+			back := Ulaw2Linear(ulaw)
+
+			// Allow tolerance due to mu-law compression (increases with magnitude)
+			tolerance := int16(10)
+			if abs(val) >= 1000 {
+				tolerance = int16(100)
+			}
+			if abs(val) >= 10000 {
+				tolerance = int16(1000)
+			}
+
+			if abs(back-val) > tolerance {
+				t.Errorf("Round trip failed: %d -> 0x%02X -> %d (tolerance: %d)", val, ulaw, back, tolerance)
+			}
+		})
+	}
+}
+
+func TestUlawCodeRoundTrip(t *testing.T) {
+	// Every mu-law code except negative zero (0x7F) must survive decode/encode unchanged
+	for code := 0; code < 256; code++ {
+		if code == 0x7F {
+			continue
+		}
+		back := Linear2Ulaw(Ulaw2Linear(uint8(code)))
+		if back != uint8(code) {
+			t.Errorf("mu-law code 0x%02X -> %d -> 0x%02X", code, Ulaw2Linear(uint8(code)), back)
+		}
+	}
+}
+
+func TestUlawBufferConversion(t *testing.T) {
+	// Test buffer conversion functions
+	pcmData := []int16{0, 100, -100, 1000, -1000}
+	ulawData := make([]byte, len(pcmData))
+	backPcmData := make([]int16, len(pcmData))
+
+	// PCM -> mu-law -> PCM
+	PCM16BufferToUlaw(pcmData, ulawData)
+	UlawBufferToPCM16(ulawData, backPcmData)
+
+	for i := 0; i < len(pcmData); i++ {
+		tolerance := int16(10)
+		if abs(pcmData[i]) >= 1000 {
+			tolerance = int16(100)
+		}
+		if abs(backPcmData[i]-pcmData[i]) > tolerance {
+			t.Errorf("Buffer conversion failed at index %d: %d -> %d", i, pcmData[i], backPcmData[i])
+		}
+	}
+}
+
+func TestUlawSilence(t *testing.T) {
+	// Test that mu-law silence (0xFF) converts to 0
+	silence := uint8(0xFF)
+	result := Ulaw2Linear(silence)
+	if result != 0 {
+		t.Errorf("mu-law silence (0xFF) should convert to 0, got %d", result)
+	}
+
+	// Test that 0 converts to mu-law silence
+	zero := int16(0)
+	resultUlaw := Linear2Ulaw(zero)
+	if resultUlaw != 0xFF {
+		t.Errorf("Linear 0 should convert to mu-law silence (0xFF), got 0x%02X", resultUlaw)
+	}
+}
@@ -0,0 +1,249 @@
+package audio
+
+// RIFF/WAVE container support
+// Reads and writes the canonical header layout used by PCM, IEEE float and G.711 WAV files
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	WAVFormatPCM        = 0x0001 // Linear PCM
+	WAVFormatIEEEFloat  = 0x0003 // IEEE 754 floating point
+	WAVFormatALaw       = 0x0006 // ITU-T G.711 A-law
+	WAVFormatMuLaw      = 0x0007 // ITU-T G.711 mu-law
+	WAVFormatExtensible = 0xFFFE // WAVE_FORMAT_EXTENSIBLE (sub-format in GUID)
+
+	wavUnknownSize = 0xFFFFFFFF // Data size used by streaming writers
+	wavFmtMaxSize  = 40         // Size of a WAVE_FORMAT_EXTENSIBLE fmt chunk
+)
+
+// WAVHeader describes the format of a RIFF/WAVE stream
+type WAVHeader struct {
+	AudioFormat   uint16 // WAV format tag (WAVFormatPCM, WAVFormatALaw, ...)
+	NumChannels   uint16 // Number of interleaved channels
+	SampleRate    uint32 // Sample rate in Hz
+	BitsPerSample uint16 // Bits per sample of a single channel
+	DataSize      uint32 // Size of the data chunk in bytes (0xFFFFFFFF if unknown)
+}
+
+// BlockAlign returns the size of one interleaved sample frame in bytes
+func (h *WAVHeader) BlockAlign() int {
+	return int(h.NumChannels) * int(h.BitsPerSample) / 8
+}
+
+// HasWAVExtension returns true if the path has a .wav extension
+func HasWAVExtension(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".wav")
+}
+
+// IsWAV reports whether the buffered stream starts with a RIFF/WAVE header
+// The stream position is not advanced
+func IsWAV(r *bufio.Reader) bool {
+	magic, err := r.Peek(12)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(magic[0:4], []byte("RIFF")) && bytes.Equal(magic[8:12], []byte("WAVE"))
+}
+
+// ReadWAVHeader parses RIFF/WAVE chunks up to the start of the data chunk
+// On success the reader is positioned at the first sample
+func ReadWAVHeader(r io.Reader) (*WAVHeader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE stream")
+	}
+
+	var header *WAVHeader
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("fmt chunk too short: %d bytes", size)
+			}
+			// Only the WAVE_FORMAT_EXTENSIBLE fields are needed; the rest
+			// of an oversized chunk is skipped rather than buffered
+			data := make([]byte, min(size, wavFmtMaxSize))
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(size)-int64(len(data))+int64(size%2)); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			header = &WAVHeader{
+				AudioFormat:   binary.LittleEndian.Uint16(data[0:2]),
+				NumChannels:   binary.LittleEndian.Uint16(data[2:4]),
+				SampleRate:    binary.LittleEndian.Uint32(data[4:8]),
+				BitsPerSample: binary.LittleEndian.Uint16(data[14:16]),
+			}
+			// WAVE_FORMAT_EXTENSIBLE carries the real format tag in the first
+			// two bytes of the sub-format GUID
+			if header.AudioFormat == WAVFormatExtensible && size >= 40 {
+				header.AudioFormat = binary.LittleEndian.Uint16(data[24:26])
+			}
+
+		case "data":
+			if header == nil {
+				return nil, errors.New("data chunk found before fmt chunk")
+			}
+			header.DataSize = size
+			return header, nil
+
+		default:
+			// Skip unknown chunks (LIST, fact, ...), honouring the pad byte
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// WAVWriter writes audio data with a RIFF/WAVE header
+// If the underlying writer is an io.WriteSeeker the chunk sizes are patched on Close,
+// otherwise they are left as 0xFFFFFFFF (streaming WAV)
+type WAVWriter struct {
+	w        io.Writer
+	header   WAVHeader
+	dataSize uint32
+}
+
+// NewWAVWriter writes a WAV header for the given format and returns a writer for the sample data
+func NewWAVWriter(w io.Writer, audioFormat uint16, sampleRate, numChannels int) (*WAVWriter, error) {
+	var bits uint16
+	switch audioFormat {
+	case WAVFormatPCM:
+		bits = 16
+	case WAVFormatIEEEFloat:
+		bits = 32
+	case WAVFormatALaw, WAVFormatMuLaw:
+		bits = 8
+	default:
+		return nil, fmt.Errorf("unsupported WAV format tag: 0x%04X", audioFormat)
+	}
+	if sampleRate <= 0 || numChannels <= 0 {
+		return nil, errors.New("invalid parameters")
+	}
+
+	ww := &WAVWriter{
+		w: w,
+		header: WAVHeader{
+			AudioFormat:   audioFormat,
+			NumChannels:   uint16(numChannels),
+			SampleRate:    uint32(sampleRate),
+			BitsPerSample: bits,
+			DataSize:      wavUnknownSize,
+		},
+	}
+	if _, err := w.Write(ww.encodeHeader()); err != nil {
+		return nil, fmt.Errorf("failed to write WAV header: %w", err)
+	}
+	return ww, nil
+}
+
+// Write writes sample data to the data chunk
+func (ww *WAVWriter) Write(p []byte) (int, error) {
+	n, err := ww.w.Write(p)
+	ww.dataSize += uint32(n)
+	return n, err
+}
+
+// Close finalizes the data chunk and, when possible, rewrites the header with the actual sizes
+// The underlying writer is not closed
+func (ww *WAVWriter) Close() error {
+	// Chunks must be word-aligned
+	if ww.dataSize%2 != 0 {
+		if _, err := ww.w.Write([]byte{0}); err != nil {
+			return fmt.Errorf("failed to write pad byte: %w", err)
+		}
+	}
+
+	seeker, ok := ww.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	end, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// Not actually seekable (pipe, terminal): keep the streaming header
+		return nil
+	}
+
+	ww.header.DataSize = ww.dataSize
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind WAV output: %w", err)
+	}
+	if _, err := seeker.Write(ww.encodeHeader()); err != nil {
+		return fmt.Errorf("failed to update WAV header: %w", err)
+	}
+	if _, err := seeker.Seek(end, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek WAV output: %w", err)
+	}
+	return nil
+}
+
+// encodeHeader serializes the RIFF, fmt (and fact for non-PCM formats) and data chunk headers
+func (ww *WAVWriter) encodeHeader() []byte {
+	h := ww.header
+	pcm := h.AudioFormat == WAVFormatPCM
+
+	fmtSize := uint32(16)
+	if !pcm {
+		fmtSize = 18 // cbSize is required for non-PCM formats
+	}
+
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, le, uint32(0)) // patched below
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, le, fmtSize)
+	binary.Write(&buf, le, h.AudioFormat)
+	binary.Write(&buf, le, h.NumChannels)
+	binary.Write(&buf, le, h.SampleRate)
+	binary.Write(&buf, le, h.SampleRate*uint32(h.BlockAlign()))
+	binary.Write(&buf, le, uint16(h.BlockAlign()))
+	binary.Write(&buf, le, h.BitsPerSample)
+	if !pcm {
+		binary.Write(&buf, le, uint16(0))
+
+		// Non-PCM formats carry the number of sample frames in a fact chunk
+		sampleFrames := uint32(wavUnknownSize)
+		if h.DataSize != wavUnknownSize {
+			sampleFrames = h.DataSize / uint32(h.BlockAlign())
+		}
+		buf.WriteString("fact")
+		binary.Write(&buf, le, uint32(4))
+		binary.Write(&buf, le, sampleFrames)
+	}
+
+	buf.WriteString("data")
+	binary.Write(&buf, le, h.DataSize)
+
+	out := buf.Bytes()
+	riffSize := uint32(wavUnknownSize)
+	if h.DataSize != wavUnknownSize {
+		riffSize = uint32(len(out)) - 8 + h.DataSize + h.DataSize%2
+	}
+	le.PutUint32(out[4:8], riffSize)
+	return out
+}
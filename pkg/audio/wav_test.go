@@ -0,0 +1,209 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		audioFormat uint16
+		bits        uint16
+	}{
+		{name: "pcm16", audioFormat: WAVFormatPCM, bits: 16},
+		{name: "alaw", audioFormat: WAVFormatALaw, bits: 8},
+		{name: "mulaw", audioFormat: WAVFormatMuLaw, bits: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.wav")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+
+			ww, err := NewWAVWriter(file, tt.audioFormat, 16000, 1)
+			if err != nil {
+				t.Fatalf("NewWAVWriter() error = %v", err)
+			}
+			payload := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+			if _, err := ww.Write(payload); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := ww.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			file.Close()
+
+			file, err = os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open file: %v", err)
+			}
+			defer file.Close()
+
+			br := bufio.NewReader(file)
+			if !IsWAV(br) {
+				t.Fatalf("IsWAV() = false, want true")
+			}
+			header, err := ReadWAVHeader(br)
+			if err != nil {
+				t.Fatalf("ReadWAVHeader() error = %v", err)
+			}
+			if header.AudioFormat != tt.audioFormat || header.NumChannels != 1 ||
+				header.SampleRate != 16000 || header.BitsPerSample != tt.bits {
+				t.Errorf("ReadWAVHeader() = %+v", header)
+			}
+			if header.DataSize != uint32(len(payload)) {
+				t.Errorf("DataSize = %d, want %d", header.DataSize, len(payload))
+			}
+
+			data, err := io.ReadAll(io.LimitReader(br, int64(header.DataSize)))
+			if err != nil {
+				t.Fatalf("Failed to read data: %v", err)
+			}
+			if !bytes.Equal(data, payload) {
+				t.Errorf("Data = %v, want %v", data, payload)
+			}
+		})
+	}
+}
+
+func TestWAVWriterStreaming(t *testing.T) {
+	// A plain io.Writer cannot be rewound, so sizes stay unknown
+	var buf bytes.Buffer
+	ww, err := NewWAVWriter(&buf, WAVFormatPCM, 8000, 1)
+	if err != nil {
+		t.Fatalf("NewWAVWriter() error = %v", err)
+	}
+	ww.Write([]byte{0, 0, 0, 0})
+	if err := ww.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	header, err := ReadWAVHeader(&buf)
+	if err != nil {
+		t.Fatalf("ReadWAVHeader() error = %v", err)
+	}
+	if header.DataSize != 0xFFFFFFFF {
+		t.Errorf("DataSize = 0x%X, want 0xFFFFFFFF", header.DataSize)
+	}
+}
+
+func TestReadWAVHeaderSkipsChunks(t *testing.T) {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, le, uint32(0))
+	buf.WriteString("WAVE")
+
+	// Odd-sized LIST chunk followed by its pad byte
+	buf.WriteString("LIST")
+	binary.Write(&buf, le, uint32(3))
+	buf.Write([]byte{'a', 'b', 'c', 0})
+
+	// WAVE_FORMAT_EXTENSIBLE fmt chunk with A-law sub-format
+	buf.WriteString("fmt ")
+	binary.Write(&buf, le, uint32(40))
+	binary.Write(&buf, le, uint16(WAVFormatExtensible))
+	binary.Write(&buf, le, uint16(1))
+	binary.Write(&buf, le, uint32(8000))
+	binary.Write(&buf, le, uint32(8000))
+	binary.Write(&buf, le, uint16(1))
+	binary.Write(&buf, le, uint16(8))
+	binary.Write(&buf, le, uint16(22))
+	binary.Write(&buf, le, uint16(8))
+	binary.Write(&buf, le, uint32(0))
+	binary.Write(&buf, le, uint16(WAVFormatALaw))
+	buf.Write(make([]byte, 14))
+
+	buf.WriteString("data")
+	binary.Write(&buf, le, uint32(2))
+	buf.Write([]byte{0xD5, 0xD5})
+
+	header, err := ReadWAVHeader(&buf)
+	if err != nil {
+		t.Fatalf("ReadWAVHeader() error = %v", err)
+	}
+	if header.AudioFormat != WAVFormatALaw {
+		t.Errorf("AudioFormat = 0x%04X, want 0x%04X", header.AudioFormat, WAVFormatALaw)
+	}
+	if header.SampleRate != 8000 || header.DataSize != 2 {
+		t.Errorf("ReadWAVHeader() = %+v", header)
+	}
+}
+
+func TestReadWAVHeaderOversizedFmt(t *testing.T) {
+	le := binary.LittleEndian
+	fmtChunk := func(buf *bytes.Buffer, size uint32) {
+		buf.WriteString("RIFF")
+		binary.Write(buf, le, uint32(0))
+		buf.WriteString("WAVE")
+		buf.WriteString("fmt ")
+		binary.Write(buf, le, size)
+		binary.Write(buf, le, uint16(WAVFormatPCM))
+		binary.Write(buf, le, uint16(1))
+		binary.Write(buf, le, uint32(16000))
+		binary.Write(buf, le, uint32(32000))
+		binary.Write(buf, le, uint16(2))
+		binary.Write(buf, le, uint16(16))
+	}
+
+	// Odd-sized fmt chunk with trailing extra bytes and its pad byte
+	var buf bytes.Buffer
+	fmtChunk(&buf, 1001)
+	buf.Write(make([]byte, 1001-16+1))
+	buf.WriteString("data")
+	binary.Write(&buf, le, uint32(4))
+	buf.Write([]byte{1, 0, 2, 0})
+
+	header, err := ReadWAVHeader(&buf)
+	if err != nil {
+		t.Fatalf("ReadWAVHeader() error = %v", err)
+	}
+	if header.SampleRate != 16000 || header.BitsPerSample != 16 || header.DataSize != 4 {
+		t.Errorf("ReadWAVHeader() = %+v", header)
+	}
+	if buf.Len() != 4 {
+		t.Errorf("reader left with %d bytes, want the 4 data bytes", buf.Len())
+	}
+
+	// A truncated stream claiming a 4 GiB fmt chunk fails without buffering it
+	buf.Reset()
+	fmtChunk(&buf, 0xFFFFFFFF)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := ReadWAVHeader(&buf); err == nil {
+		t.Errorf("ReadWAVHeader() expected error for truncated fmt chunk")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("ReadWAVHeader() allocated %d bytes for a truncated fmt chunk", allocated)
+	}
+}
+
+func TestReadWAVHeaderInvalid(t *testing.T) {
+	if _, err := ReadWAVHeader(bytes.NewReader([]byte("not a wav file at all"))); err == nil {
+		t.Errorf("ReadWAVHeader() expected error for non-WAV input")
+	}
+	if IsWAV(bufio.NewReader(bytes.NewReader([]byte{0xD5, 0xD5}))) {
+		t.Errorf("IsWAV() = true for raw A-law data")
+	}
+}
+
+func TestHasWAVExtension(t *testing.T) {
+	if !HasWAVExtension("out.WAV") || !HasWAVExtension("dir/in.wav") {
+		t.Errorf("HasWAVExtension() = false for .wav path")
+	}
+	if HasWAVExtension("out.alaw") {
+		t.Errorf("HasWAVExtension() = true for .alaw path")
+	}
+}
@@ -0,0 +1,66 @@
+// Package pipeline runs the open_tool_speex processing pipeline: it reads the mic and speaker
+// streams, cancels echo and suppresses noise as configured and writes the output and reports
+//
+// Lifecycle: New copies and validates the configuration. Run opens, processes and closes all
+// files of one run, so a Pipeline holds no resources between runs and needs no cleanup.
+// A Pipeline may be run repeatedly; concurrent runs must not share output files.
+//
+// RunStreams processes caller-provided readers and writers instead of files. It neither
+// closes the streams nor keeps them after returning. Cancellation is checked between frames;
+// a Read or Write blocked inside a stream is not interrupted.
+package pipeline
+
+import (
+	"context"
+
+	"open_tool_speex/internal/config"
+	"open_tool_speex/internal/processor"
+	"open_tool_speex/pkg/types"
+)
+
+// Pipeline processes the files of one configuration
+type Pipeline struct {
+	config types.Config
+}
+
+// DefaultConfig returns the default configuration: 16 kHz A-law, 20 ms frames,
+// echo cancellation followed by noise suppression
+func DefaultConfig() types.Config {
+	return types.DefaultConfig()
+}
+
+// New builds a pipeline from a configuration
+// Settings implied by others are derived (the filter length from EchoTailMs, VAD for the
+// VAD outputs) and the result is validated with the same rules as the command line
+func New(cfg types.Config) (*Pipeline, error) {
+	if err := config.Prepare(&cfg); err != nil {
+		return nil, err
+	}
+	return &Pipeline{config: cfg}, nil
+}
+
+// Config returns the configuration of the pipeline including the derived settings
+func (p *Pipeline) Config() types.Config {
+	return p.config
+}
+
+// Run processes the configured inputs into the configured outputs
+// Progress and summaries are printed to standard error
+func (p *Pipeline) Run() error {
+	return p.RunContext(context.Background())
+}
+
+// RunContext is Run stopping early with the error of ctx once it is cancelled
+func (p *Pipeline) RunContext(ctx context.Context) error {
+	cfg := p.config
+	return processor.NewProcessor(&cfg).ProcessContext(ctx)
+}
+
+// RunStreams processes streams in place of the input and output files of cfg
+// Reports (metrics, VAD outputs, segments) are still written to the files named in cfg
+func RunStreams(ctx context.Context, cfg types.Config, streams types.Streams) error {
+	if err := config.PrepareStreams(&cfg, streams); err != nil {
+		return err
+	}
+	return processor.NewProcessor(&cfg).ProcessStreams(ctx, streams)
+}
@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"open_tool_speex/pkg/types"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *types.Config)
+		wantErr bool
+		check   func(cfg types.Config) bool
+	}{
+		{
+			name:   "derives filter length",
+			modify: func(cfg *types.Config) {},
+			check: func(cfg types.Config) bool {
+				return cfg.FilterLen == 3200
+			},
+		},
+		{
+			name: "keeps explicit filter length",
+			modify: func(cfg *types.Config) {
+				cfg.FilterLen = 1024
+			},
+			check: func(cfg types.Config) bool {
+				return cfg.FilterLen == 1024
+			},
+		},
+		{
+			name: "vad outputs enable vad",
+			modify: func(cfg *types.Config) {
+				cfg.VADCSV = "vad.csv"
+			},
+			check: func(cfg types.Config) bool {
+				return cfg.NS.EnableVAD
+			},
+		},
+		{
+			name: "missing speaker",
+			modify: func(cfg *types.Config) {
+				cfg.SpeakerFile = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "upper-case chain",
+			modify: func(cfg *types.Config) {
+				cfg.Chain = []string{"HPF", "AEC", " Ns"}
+			},
+			check: func(cfg types.Config) bool {
+				return len(cfg.Chain) == 3 && cfg.Chain[1] == types.StageAEC && cfg.Chain[2] == types.StageNS
+			},
+		},
+		{
+			name: "upper-case chain requires speaker",
+			modify: func(cfg *types.Config) {
+				cfg.Chain = []string{"AEC", "NS"}
+				cfg.SpeakerFile = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "upper-case chain allows metrics",
+			modify: func(cfg *types.Config) {
+				cfg.Chain = []string{"AEC"}
+				cfg.MetricsFile = "metrics.json"
+			},
+			check: func(cfg types.Config) bool {
+				return cfg.HasStage(types.StageAEC)
+			},
+		},
+		{
+			name: "invalid option combination",
+			modify: func(cfg *types.Config) {
+				cfg.Mode = types.ModeNSOnly
+				cfg.MetricsFile = "metrics.json"
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.MicFile = "mic.alaw"
+			cfg.SpeakerFile = "spk.alaw"
+			tt.modify(&cfg)
+
+			p, err := New(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.check(p.Config()) {
+				t.Errorf("New() config = %+v", p.Config())
+			}
+		})
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	tempDir := t.TempDir()
+	micFile := filepath.Join(tempDir, "mic.alaw")
+	outputFile := filepath.Join(tempDir, "output.alaw")
+
+	input := bytes.Repeat([]byte{0x55, 0xD5, 0x2A, 0xAA}, 320)
+	if err := os.WriteFile(micFile, input, 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.MicFile = micFile
+	cfg.OutputFile = outputFile
+	cfg.Mode = types.ModeBypass
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// A pipeline can be run more than once
+	for run := 0; run < 2; run++ {
+		if err := p.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		output, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		if !bytes.Equal(output, input) {
+			t.Fatalf("run %d: bypass output differs from input", run)
+		}
+	}
+}
+
+func TestRunStreams(t *testing.T) {
+	input := bytes.Repeat([]byte{0x55, 0xD5, 0x2A, 0xAA}, 320)
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *types.Config, streams *types.Streams)
+		cancel  bool
+		wantErr bool
+	}{
+		{
+			name:   "bypass",
+			modify: func(cfg *types.Config, streams *types.Streams) {},
+		},
+		{
+			name: "missing output",
+			modify: func(cfg *types.Config, streams *types.Streams) {
+				streams.Output = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing speaker",
+			modify: func(cfg *types.Config, streams *types.Streams) {
+				cfg.Mode = types.ModeAECFirst
+			},
+			wantErr: true,
+		},
+		{
+			name: "multi-channel",
+			modify: func(cfg *types.Config, streams *types.Streams) {
+				cfg.ExtraMicFiles = []string{"mic1.alaw"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "cancelled",
+			modify:  func(cfg *types.Config, streams *types.Streams) {},
+			cancel:  true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Mode = types.ModeBypass
+			var output bytes.Buffer
+			streams := types.Streams{Mic: bytes.NewReader(input), Output: &output}
+			tt.modify(&cfg, &streams)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			err := RunStreams(ctx, cfg, streams)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunStreams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.cancel && !errors.Is(err, context.Canceled) {
+				t.Errorf("RunStreams() error = %v, want context.Canceled", err)
+			}
+			if err == nil && !bytes.Equal(output.Bytes(), input) {
+				t.Errorf("bypass output differs from input")
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package speex
+
+/*
+#cgo pkg-config: speexdsp
+#include <speex/speex_echo.h>
+#include <speex/speex_preprocess.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+
+	"open_tool_speex/pkg/types"
+)
+
+// AEC wraps Speex Echo Canceller and Preprocessor
+type AEC struct {
+	echoState    *C.SpeexEchoState
+	preprocState *C.SpeexPreprocessState
+	frameSize    int
+	filterLen    int
+	sampleRate   int
+	config       types.NSConfig
+}
+
+// NewAEC creates new Speex AEC instance with default preprocessor settings
+// frameSize: samples per frame (320 for 20ms at 16kHz)
+// filterLen: echo tail length in samples (3200 for 200ms at 16kHz)
+// sampleRate: sample rate in Hz (16000)
+func NewAEC(frameSize, filterLen, sampleRate int) (*AEC, error) {
+	return NewAECWithConfig(frameSize, filterLen, sampleRate, types.DefaultConfig().NS)
+}
+
+// NewAECWithConfig creates new Speex AEC instance whose linked preprocessor
+// (used by ProcessFrame) follows the given noise suppression, VAD, AGC and residual echo settings
+func NewAECWithConfig(frameSize, filterLen, sampleRate int, config types.NSConfig) (*AEC, error) {
+	if frameSize <= 0 || filterLen <= 0 || sampleRate <= 0 {
+		return nil, errors.New("invalid parameters")
+	}
+
+	// Create echo state
+	echoState := C.speex_echo_state_init(C.int(frameSize), C.int(filterLen))
+	if echoState == nil {
+		return nil, errors.New("failed to create echo state")
+	}
+
+	// Set sample rate
+	rate := C.int(sampleRate)
+	C.speex_echo_ctl(echoState, C.SPEEX_ECHO_SET_SAMPLING_RATE, unsafe.Pointer(&rate))
+
+	// Create preprocessor state
+	preprocState := C.speex_preprocess_state_init(C.int(frameSize), C.int(sampleRate))
+	if preprocState == nil {
+		C.speex_echo_state_destroy(echoState)
+		return nil, errors.New("failed to create preprocessor state")
+	}
+
+	// Configure preprocessor and link it to the echo state
+	configureEchoPreprocessor(preprocState, echoState, config)
+
+	return &AEC{
+		echoState:    echoState,
+		preprocState: preprocState,
+		frameSize:    frameSize,
+		filterLen:    filterLen,
+		sampleRate:   sampleRate,
+		config:       config,
+	}, nil
+}
+
+// configureEchoPreprocessor configures a preprocessor, links it to an echo state and applies
+// the residual echo suppression settings
+func configureEchoPreprocessor(preprocState *C.SpeexPreprocessState, echoState *C.SpeexEchoState, config types.NSConfig) {
+	configurePreprocessor(preprocState, config)
+
+	// Link echo state to preprocessor
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_ECHO_STATE, unsafe.Pointer(echoState))
+
+	// Configure residual echo suppression (0 dB disables it)
+	ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_ECHO_SUPPRESS, config.EchoSuppress)
+	ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_ECHO_SUPPRESS_ACTIVE, config.EchoSuppressActive)
+}
+
+// errFrameSize reports a frame whose length does not match the configured frame size
+var errFrameSize = errors.New("invalid frame size")
+
+// ProcessFrame processes a frame with both echo cancellation and noise suppression
+func (aec *AEC) ProcessFrame(micFrame, speakerFrame []int16) []int16 {
+	output, _ := aec.ProcessFrameVAD(micFrame, speakerFrame)
+	return output
+}
+
+// ProcessFrameVAD processes a frame with echo cancellation and noise suppression
+// and returns the voice activity of the echo-cancelled signal
+func (aec *AEC) ProcessFrameVAD(micFrame, speakerFrame []int16) ([]int16, VADResult) {
+	output := make([]int16, aec.frameSize)
+	vad, err := aec.ProcessFrameInto(output, micFrame, speakerFrame)
+	if err != nil {
+		return nil, VADResult{}
+	}
+	return output, vad
+}
+
+// ProcessFrameInto is ProcessFrameVAD writing into a caller-owned frame
+// dst must not overlap micFrame or speakerFrame
+func (aec *AEC) ProcessFrameInto(dst, micFrame, speakerFrame []int16) (VADResult, error) {
+	if err := aec.ProcessFrameEchoOnlyInto(dst, micFrame, speakerFrame); err != nil {
+		return VADResult{}, err
+	}
+	return runPreprocessor(aec.preprocState, (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))), nil
+}
+
+// Preprocess applies the linked preprocessor to a frame returned by ProcessFrameEchoOnly
+// ProcessFrameEchoOnly followed by Preprocess is equivalent to ProcessFrameVAD
+func (aec *AEC) Preprocess(echoFrame []int16) ([]int16, VADResult) {
+	output := make([]int16, aec.frameSize)
+	vad, err := aec.PreprocessInto(output, echoFrame)
+	if err != nil {
+		return nil, VADResult{}
+	}
+	return output, vad
+}
+
+// PreprocessInto is Preprocess writing into a caller-owned frame
+// dst may be echoFrame itself to preprocess in place
+func (aec *AEC) PreprocessInto(dst, echoFrame []int16) (VADResult, error) {
+	if len(dst) != aec.frameSize || len(echoFrame) != aec.frameSize {
+		return VADResult{}, errFrameSize
+	}
+	copy(dst, echoFrame)
+	return runPreprocessor(aec.preprocState, (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))), nil
+}
+
+// ProcessFrameEchoOnly processes a frame with only echo cancellation (no noise suppression)
+func (aec *AEC) ProcessFrameEchoOnly(micFrame, speakerFrame []int16) []int16 {
+	output := make([]int16, aec.frameSize)
+	if err := aec.ProcessFrameEchoOnlyInto(output, micFrame, speakerFrame); err != nil {
+		return nil
+	}
+	return output
+}
+
+// ProcessFrameEchoOnlyInto is ProcessFrameEchoOnly writing into a caller-owned frame
+// dst must not overlap micFrame or speakerFrame
+func (aec *AEC) ProcessFrameEchoOnlyInto(dst, micFrame, speakerFrame []int16) error {
+	if len(dst) != aec.frameSize || len(micFrame) != aec.frameSize || len(speakerFrame) != aec.frameSize {
+		return errFrameSize
+	}
+
+	micPtr := (*C.spx_int16_t)(unsafe.Pointer(&micFrame[0]))
+	speakerPtr := (*C.spx_int16_t)(unsafe.Pointer(&speakerFrame[0]))
+	outPtr := (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))
+
+	// Apply only echo cancellation
+	C.speex_echo_cancellation(aec.echoState, micPtr, speakerPtr, outPtr)
+	return nil
+}
+
+// Playback queues a frame sent to the speaker for cancellation in a later Capture
+// Speex buffers up to two frames ahead of the capture side and discards playback before the first Capture
+// Do not mix Playback/Capture with ProcessFrame on the same instance
+func (aec *AEC) Playback(speakerFrame []int16) error {
+	if len(speakerFrame) != aec.frameSize {
+		return errFrameSize
+	}
+	C.speex_echo_playback(aec.echoState, (*C.spx_int16_t)(unsafe.Pointer(&speakerFrame[0])))
+	return nil
+}
+
+// Capture cancels the echo of the queued playback from a captured frame and applies the preprocessor
+// Without a queued playback frame the mic frame passes the echo canceller unchanged
+func (aec *AEC) Capture(micFrame []int16) []int16 {
+	output := make([]int16, aec.frameSize)
+	if err := aec.CaptureInto(output, micFrame); err != nil {
+		return nil
+	}
+	return output
+}
+
+// CaptureInto is Capture writing into a caller-owned frame
+// dst must not overlap micFrame
+func (aec *AEC) CaptureInto(dst, micFrame []int16) error {
+	if len(dst) != aec.frameSize || len(micFrame) != aec.frameSize {
+		return errFrameSize
+	}
+
+	micPtr := (*C.spx_int16_t)(unsafe.Pointer(&micFrame[0]))
+	outPtr := (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))
+
+	C.speex_echo_capture(aec.echoState, micPtr, outPtr)
+	runPreprocessor(aec.preprocState, outPtr)
+	return nil
+}
+
+// ImpulseResponse returns the echo path learned by the adaptive filter, one tap per sample
+// The filter length is rounded up to whole frames; 32767 corresponds to unity gain
+func (aec *AEC) ImpulseResponse() []int32 {
+	var size C.spx_int32_t
+	if C.speex_echo_ctl(aec.echoState, C.SPEEX_ECHO_GET_IMPULSE_RESPONSE_SIZE, unsafe.Pointer(&size)) != 0 || size <= 0 {
+		return nil
+	}
+	response := make([]int32, size)
+	if C.speex_echo_ctl(aec.echoState, C.SPEEX_ECHO_GET_IMPULSE_RESPONSE, unsafe.Pointer(&response[0])) != 0 {
+		return nil
+	}
+	return response
+}
+
+// Reset resets the echo canceller state
+// This also drops the queued playback frames; the linked preprocessor keeps its state (see ResetPreprocessor)
+func (aec *AEC) Reset() {
+	if aec.echoState != nil {
+		C.speex_echo_state_reset(aec.echoState)
+	}
+}
+
+// ResetPreprocessor discards the adapted noise, VAD and AGC state of the linked preprocessor
+// Speex has no reset request, so the state is recreated with the same configuration
+func (aec *AEC) ResetPreprocessor() {
+	if aec.preprocState == nil {
+		return
+	}
+	preprocState := C.speex_preprocess_state_init(C.int(aec.frameSize), C.int(aec.sampleRate))
+	if preprocState == nil {
+		return
+	}
+	configureEchoPreprocessor(preprocState, aec.echoState, aec.config)
+	C.speex_preprocess_state_destroy(aec.preprocState)
+	aec.preprocState = preprocState
+}
+
+// Destroy cleans up resources
+func (aec *AEC) Destroy() {
+	if aec.preprocState != nil {
+		C.speex_preprocess_state_destroy(aec.preprocState)
+	}
+	if aec.echoState != nil {
+		C.speex_echo_state_destroy(aec.echoState)
+	}
+}
@@ -0,0 +1,170 @@
+package speex
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"open_tool_speex/pkg/types"
+)
+
+const (
+	testFrameSize  = 160
+	testFilterLen  = 1600
+	testSampleRate = 16000
+)
+
+func newTestAEC(t *testing.T) *AEC {
+	t.Helper()
+	aec, err := NewAECWithConfig(testFrameSize, testFilterLen, testSampleRate, types.DefaultConfig().NS)
+	if err != nil {
+		t.Fatalf("NewAECWithConfig() error = %v", err)
+	}
+	return aec
+}
+
+// noiseFrames returns speaker frames of white noise and mic frames carrying their echo at half level
+func noiseFrames(frames int) (mic, speaker [][]int16) {
+	rng := rand.New(rand.NewSource(1))
+	for f := 0; f < frames; f++ {
+		spk := make([]int16, testFrameSize)
+		m := make([]int16, testFrameSize)
+		for i := range spk {
+			spk[i] = int16(rng.NormFloat64() * 4000)
+			m[i] = spk[i] / 2
+		}
+		speaker = append(speaker, spk)
+		mic = append(mic, m)
+	}
+	return mic, speaker
+}
+
+// energy returns the summed squared samples of frames
+func energy(frames [][]int16) float64 {
+	var sum float64
+	for _, frame := range frames {
+		for _, s := range frame {
+			sum += float64(s) * float64(s)
+		}
+	}
+	return sum
+}
+
+func TestAEC_PlaybackCapture(t *testing.T) {
+	aec := newTestAEC(t)
+	defer aec.Destroy()
+
+	const frames = 500
+	mic, speaker := noiseFrames(frames)
+	var output [][]int16
+	for i := range mic {
+		if err := aec.Playback(speaker[i]); err != nil {
+			t.Fatalf("Playback() error = %v", err)
+		}
+		out := make([]int16, testFrameSize)
+		if err := aec.CaptureInto(out, mic[i]); err != nil {
+			t.Fatalf("CaptureInto() error = %v", err)
+		}
+		output = append(output, out)
+	}
+
+	// Compare the second half, after the filter has converged
+	erle := 10 * math.Log10(energy(mic[frames/2:])/math.Max(energy(output[frames/2:]), 1))
+	if erle < 6 {
+		t.Errorf("echo reduced by %.1f dB, want at least 6 dB", erle)
+	}
+}
+
+func TestAEC_CaptureWithoutPlayback(t *testing.T) {
+	aec := newTestAEC(t)
+	defer aec.Destroy()
+
+	mic := make([]int16, testFrameSize)
+	for i := range mic {
+		mic[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/testSampleRate))
+	}
+	output := aec.Capture(mic)
+	if len(output) != testFrameSize {
+		t.Fatalf("Capture() returned %d samples, want %d", len(output), testFrameSize)
+	}
+	if energy([][]int16{output}) == 0 {
+		t.Errorf("Capture() without playback removed the mic signal")
+	}
+}
+
+func TestAEC_FrameSizeErrors(t *testing.T) {
+	aec := newTestAEC(t)
+	defer aec.Destroy()
+
+	frame := make([]int16, testFrameSize)
+	short := make([]int16, testFrameSize-1)
+
+	if err := aec.Playback(short); err == nil {
+		t.Errorf("Playback() with a short frame succeeded")
+	}
+	if out := aec.Capture(short); out != nil {
+		t.Errorf("Capture() with a short frame = %d samples, want nil", len(out))
+	}
+	if err := aec.CaptureInto(short, frame); err == nil {
+		t.Errorf("CaptureInto() with a short dst succeeded")
+	}
+	if err := aec.CaptureInto(frame, short); err == nil {
+		t.Errorf("CaptureInto() with a short mic frame succeeded")
+	}
+	if _, err := aec.ProcessFrameInto(frame, frame[:0], frame); err == nil {
+		t.Errorf("ProcessFrameInto() with an empty mic frame succeeded")
+	}
+	if err := aec.ProcessFrameEchoOnlyInto(frame, frame, short); err == nil {
+		t.Errorf("ProcessFrameEchoOnlyInto() with a short speaker frame succeeded")
+	}
+}
+
+func TestMultiAEC_ResidualEchoPerChannel(t *testing.T) {
+	const mics = 2
+	config := types.DefaultConfig().NS
+	newMulti := func() *MultiAEC {
+		m, err := NewMultiAEC(testFrameSize, testFilterLen, testSampleRate, mics, 1, config)
+		if err != nil {
+			t.Fatalf("NewMultiAEC() error = %v", err)
+		}
+		return m
+	}
+	full := newMulti()
+	defer full.Destroy()
+	echoOnly := newMulti()
+	defer echoOnly.Destroy()
+	reference, err := NewPreprocessorWithConfig(testFrameSize, testSampleRate, config)
+	if err != nil {
+		t.Fatalf("NewPreprocessorWithConfig() error = %v", err)
+	}
+	defer reference.Destroy()
+
+	// Mic 0 carries the echo, mic 1 only unrelated noise
+	echo, speaker := noiseFrames(200)
+	rng := rand.New(rand.NewSource(2))
+	channel := make([]int16, testFrameSize)
+	for f := range speaker {
+		mic := make([]int16, mics*testFrameSize)
+		for i := 0; i < testFrameSize; i++ {
+			mic[i*mics] = echo[f][i]
+			mic[i*mics+1] = int16(rng.NormFloat64() * 2000)
+		}
+		got := full.ProcessFrame(mic, speaker[f])
+		cancelled := echoOnly.ProcessFrameEchoOnly(mic, speaker[f])
+		if got == nil || cancelled == nil {
+			t.Fatalf("frame %d: MultiAEC returned no output", f)
+		}
+
+		// Mic 1 must match plain noise suppression of its echo-cancelled signal,
+		// not be suppressed by the residual echo of mic 0
+		for i := range channel {
+			channel[i] = cancelled[i*mics+1]
+		}
+		want := reference.ProcessFrame(channel)
+		for i := range want {
+			if got[i*mics+1] != want[i] {
+				t.Fatalf("frame %d sample %d: mic 1 = %d, want %d without residual echo suppression", f, i, got[i*mics+1], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package speex
+
+import (
+	"math"
+	"testing"
+
+	"open_tool_speex/pkg/types"
+)
+
+const (
+	benchFrameSize  = 320
+	benchFilterLen  = 3200
+	benchSampleRate = 16000
+)
+
+// benchFrames returns a mic frame carrying an attenuated copy of the speaker frame
+func benchFrames(channels int) (mic, speaker []int16) {
+	mic = make([]int16, benchFrameSize*channels)
+	speaker = make([]int16, benchFrameSize*channels)
+	for i := range speaker {
+		speaker[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i/channels)/benchSampleRate))
+		mic[i] = speaker[i] / 2
+	}
+	return mic, speaker
+}
+
+func newBenchAEC(b *testing.B) *AEC {
+	aec, err := NewAECWithConfig(benchFrameSize, benchFilterLen, benchSampleRate, types.DefaultConfig().NS)
+	if err != nil {
+		b.Fatalf("NewAECWithConfig() error = %v", err)
+	}
+	b.Cleanup(aec.Destroy)
+	return aec
+}
+
+func BenchmarkAEC_ProcessFrame(b *testing.B) {
+	aec := newBenchAEC(b)
+	mic, speaker := benchFrames(1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if aec.ProcessFrame(mic, speaker) == nil {
+			b.Fatal("ProcessFrame() failed")
+		}
+	}
+}
+
+func BenchmarkAEC_ProcessFrameInto(b *testing.B) {
+	aec := newBenchAEC(b)
+	mic, speaker := benchFrames(1)
+	out := make([]int16, benchFrameSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := aec.ProcessFrameInto(out, mic, speaker); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAEC_ProcessFrameEchoOnlyInto(b *testing.B) {
+	aec := newBenchAEC(b)
+	mic, speaker := benchFrames(1)
+	out := make([]int16, benchFrameSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := aec.ProcessFrameEchoOnlyInto(out, mic, speaker); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAEC_CaptureInto(b *testing.B) {
+	aec := newBenchAEC(b)
+	mic, speaker := benchFrames(1)
+	out := make([]int16, benchFrameSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := aec.Playback(speaker); err != nil {
+			b.Fatal(err)
+		}
+		if err := aec.CaptureInto(out, mic); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPreprocessor_ProcessFrameInto(b *testing.B) {
+	ns, err := NewPreprocessorWithConfig(benchFrameSize, benchSampleRate, types.DefaultConfig().NS)
+	if err != nil {
+		b.Fatalf("NewPreprocessorWithConfig() error = %v", err)
+	}
+	b.Cleanup(ns.Destroy)
+	mic, _ := benchFrames(1)
+	out := make([]int16, benchFrameSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ns.ProcessFrameInto(out, mic); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMultiAEC_ProcessFrameInto(b *testing.B) {
+	aec, err := NewMultiAEC(benchFrameSize, benchFilterLen, benchSampleRate, 2, 2, types.DefaultConfig().NS)
+	if err != nil {
+		b.Fatalf("NewMultiAEC() error = %v", err)
+	}
+	b.Cleanup(aec.Destroy)
+	mic, speaker := benchFrames(2)
+	out := make([]int16, len(mic))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := aec.ProcessFrameInto(out, mic, speaker); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
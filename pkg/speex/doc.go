@@ -0,0 +1,16 @@
+// Package speex wraps the SpeexDSP echo canceller, preprocessor and resampler
+//
+// Lifecycle: every type is created by its New function and holds C memory that the
+// garbage collector does not release; call Destroy exactly once when done and do not
+// use the value afterwards.
+//
+// Ownership: input frames are only read during the call and may be reused by the caller;
+// returned frames are newly allocated and belong to the caller. The *Into variants allocate
+// nothing: they write the result into the caller-owned dst, which then holds the output
+// (no other slice is returned). dst must not overlap the inputs, except for the
+// preprocessor-only calls (Preprocessor.ProcessFrameInto, AEC.PreprocessInto), where dst
+// may be the input frame itself to process in place.
+//
+// Concurrency: values are not safe for concurrent use. SyncAEC serializes an AEC for
+// playback and capture callbacks running on different goroutines.
+package speex
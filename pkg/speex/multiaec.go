@@ -0,0 +1,158 @@
+package speex
+
+/*
+#cgo pkg-config: speexdsp
+#include <speex/speex_echo.h>
+#include <speex/speex_preprocess.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+
+	"open_tool_speex/pkg/types"
+)
+
+// MultiAEC wraps a multi-channel Speex Echo Canceller (N mics, M speaker references)
+// with one preprocessor per mic channel
+// Speex estimates the residual echo from the first mic channel only, so residual echo
+// suppression runs on mic 0; the other channels get noise suppression without it
+// Frames are interleaved: sample i of channel c is at index i*channels+c
+type MultiAEC struct {
+	echoState       *C.SpeexEchoState
+	preprocStates   []*C.SpeexPreprocessState
+	frameSize       int
+	filterLen       int
+	micChannels     int
+	speakerChannels int
+	channel         []int16 // one deinterleaved channel for the preprocessor
+}
+
+// NewMultiAEC creates a multi-channel AEC whose preprocessors follow the given configuration
+// frameSize: samples per frame and channel
+// filterLen: echo tail length in samples
+func NewMultiAEC(frameSize, filterLen, sampleRate, micChannels, speakerChannels int, config types.NSConfig) (*MultiAEC, error) {
+	if frameSize <= 0 || filterLen <= 0 || sampleRate <= 0 || micChannels <= 0 || speakerChannels <= 0 {
+		return nil, errors.New("invalid parameters")
+	}
+
+	echoState := C.speex_echo_state_init_mc(C.int(frameSize), C.int(filterLen), C.int(micChannels), C.int(speakerChannels))
+	if echoState == nil {
+		return nil, errors.New("failed to create echo state")
+	}
+
+	rate := C.int(sampleRate)
+	C.speex_echo_ctl(echoState, C.SPEEX_ECHO_SET_SAMPLING_RATE, unsafe.Pointer(&rate))
+
+	m := &MultiAEC{
+		echoState:       echoState,
+		frameSize:       frameSize,
+		filterLen:       filterLen,
+		micChannels:     micChannels,
+		speakerChannels: speakerChannels,
+		channel:         make([]int16, frameSize),
+	}
+
+	// One preprocessor per mic; only mic 0 is linked to the echo state because
+	// speex_echo_get_residual reports the residual echo of the first channel
+	for ch := 0; ch < micChannels; ch++ {
+		preprocState := C.speex_preprocess_state_init(C.int(frameSize), C.int(sampleRate))
+		if preprocState == nil {
+			m.Destroy()
+			return nil, errors.New("failed to create preprocessor state")
+		}
+		if ch == 0 {
+			configureEchoPreprocessor(preprocState, echoState, config)
+		} else {
+			configurePreprocessor(preprocState, config)
+		}
+		m.preprocStates = append(m.preprocStates, preprocState)
+	}
+
+	return m, nil
+}
+
+// ProcessFrame processes an interleaved frame with echo cancellation and noise suppression
+// and returns the interleaved cleaned mic channels
+func (m *MultiAEC) ProcessFrame(micFrame, speakerFrame []int16) []int16 {
+	output := make([]int16, len(micFrame))
+	if err := m.ProcessFrameInto(output, micFrame, speakerFrame); err != nil {
+		return nil
+	}
+	return output
+}
+
+// ProcessFrameInto is ProcessFrame writing into a caller-owned interleaved frame
+// dst must not overlap micFrame or speakerFrame
+func (m *MultiAEC) ProcessFrameInto(dst, micFrame, speakerFrame []int16) error {
+	if err := m.ProcessFrameEchoOnlyInto(dst, micFrame, speakerFrame); err != nil {
+		return err
+	}
+
+	chPtr := (*C.spx_int16_t)(unsafe.Pointer(&m.channel[0]))
+	for ch, preprocState := range m.preprocStates {
+		for i := range m.channel {
+			m.channel[i] = dst[i*m.micChannels+ch]
+		}
+		C.speex_preprocess_run(preprocState, chPtr)
+		for i, sample := range m.channel {
+			dst[i*m.micChannels+ch] = sample
+		}
+	}
+	return nil
+}
+
+// ProcessFrameEchoOnly processes an interleaved frame with only echo cancellation
+func (m *MultiAEC) ProcessFrameEchoOnly(micFrame, speakerFrame []int16) []int16 {
+	output := make([]int16, len(micFrame))
+	if err := m.ProcessFrameEchoOnlyInto(output, micFrame, speakerFrame); err != nil {
+		return nil
+	}
+	return output
+}
+
+// ProcessFrameEchoOnlyInto is ProcessFrameEchoOnly writing into a caller-owned interleaved frame
+// dst must not overlap micFrame or speakerFrame
+func (m *MultiAEC) ProcessFrameEchoOnlyInto(dst, micFrame, speakerFrame []int16) error {
+	micLen := m.frameSize * m.micChannels
+	if len(dst) != micLen || len(micFrame) != micLen || len(speakerFrame) != m.frameSize*m.speakerChannels {
+		return errFrameSize
+	}
+
+	micPtr := (*C.spx_int16_t)(unsafe.Pointer(&micFrame[0]))
+	speakerPtr := (*C.spx_int16_t)(unsafe.Pointer(&speakerFrame[0]))
+	outPtr := (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))
+
+	C.speex_echo_cancellation(m.echoState, micPtr, speakerPtr, outPtr)
+	return nil
+}
+
+// MicChannels returns the number of mic channels
+func (m *MultiAEC) MicChannels() int {
+	return m.micChannels
+}
+
+// SpeakerChannels returns the number of speaker reference channels
+func (m *MultiAEC) SpeakerChannels() int {
+	return m.speakerChannels
+}
+
+// Reset resets the echo canceller state
+func (m *MultiAEC) Reset() {
+	if m.echoState != nil {
+		C.speex_echo_state_reset(m.echoState)
+	}
+}
+
+// Destroy cleans up resources
+func (m *MultiAEC) Destroy() {
+	for _, preprocState := range m.preprocStates {
+		C.speex_preprocess_state_destroy(preprocState)
+	}
+	m.preprocStates = nil
+	if m.echoState != nil {
+		C.speex_echo_state_destroy(m.echoState)
+		m.echoState = nil
+	}
+}
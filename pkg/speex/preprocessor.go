@@ -0,0 +1,193 @@
+package speex
+
+/*
+#cgo pkg-config: speexdsp
+#include <speex/speex_preprocess.h>
+#include <stdlib.h>
+
+// Preprocesses a frame and reads back the speech probability without passing Go pointers
+// to a local (which would escape to the heap on every frame)
+typedef struct {
+	int speech;
+	spx_int32_t prob;
+} vad_result;
+
+static vad_result preprocess_run(SpeexPreprocessState *st, spx_int16_t *frame) {
+	vad_result res;
+	res.speech = speex_preprocess_run(st, frame);
+	res.prob = 0;
+	speex_preprocess_ctl(st, SPEEX_PREPROCESS_GET_PROB, &res.prob);
+	return res;
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+
+	"open_tool_speex/pkg/types"
+)
+
+// Preprocessor wraps standalone Speex Preprocessor (without echo state)
+type Preprocessor struct {
+	preprocState *C.SpeexPreprocessState
+	frameSize    int
+	sampleRate   int
+	config       types.NSConfig
+}
+
+// NewPreprocessor creates new standalone Speex Preprocessor with default settings
+func NewPreprocessor(frameSize, sampleRate int) (*Preprocessor, error) {
+	return NewPreprocessorWithConfig(frameSize, sampleRate, types.DefaultConfig().NS)
+}
+
+// NewPreprocessorWithConfig creates new standalone Speex Preprocessor with custom configuration
+func NewPreprocessorWithConfig(frameSize, sampleRate int, config types.NSConfig) (*Preprocessor, error) {
+	if frameSize <= 0 || sampleRate <= 0 {
+		return nil, errors.New("invalid parameters")
+	}
+
+	preprocState := C.speex_preprocess_state_init(C.int(frameSize), C.int(sampleRate))
+	if preprocState == nil {
+		return nil, errors.New("failed to create preprocessor state")
+	}
+
+	configurePreprocessor(preprocState, config)
+
+	return &Preprocessor{
+		preprocState: preprocState,
+		frameSize:    frameSize,
+		sampleRate:   sampleRate,
+		config:       config,
+	}, nil
+}
+
+// configurePreprocessor applies noise suppression, VAD, AGC and dereverberation settings to a preprocessor state
+// Used for both the standalone preprocessor and the one linked to the echo canceller
+func configurePreprocessor(preprocState *C.SpeexPreprocessState, config types.NSConfig) {
+	// Configure denoising
+	denoise := boolToInt32(config.EnableDenoise)
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_DENOISE, unsafe.Pointer(&denoise))
+
+	// Configure noise suppression level
+	noiseLevel := C.spx_int32_t(int(config.NoiseSuppress))
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_NOISE_SUPPRESS, unsafe.Pointer(&noiseLevel))
+
+	// Configure VAD
+	vadVal := boolToInt32(config.EnableVAD)
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_VAD, unsafe.Pointer(&vadVal))
+	if config.EnableVAD {
+		probStart := C.spx_int32_t(config.VADProbStart)
+		C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_PROB_START, unsafe.Pointer(&probStart))
+
+		probCont := C.spx_int32_t(config.VADProbCont)
+		C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_PROB_CONTINUE, unsafe.Pointer(&probCont))
+	}
+
+	// Configure AGC (the level is a float in the Speex API)
+	agcVal := boolToInt32(config.EnableAGC)
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_AGC, unsafe.Pointer(&agcVal))
+	if config.EnableAGC {
+		agcLevel := C.float(config.AGCLevel)
+		C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_AGC_LEVEL, unsafe.Pointer(&agcLevel))
+
+		// AGC dynamics; the integer target replaces the level when set
+		ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_AGC_INCREMENT, config.AGCIncrement)
+		ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_AGC_DECREMENT, config.AGCDecrement)
+		ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_AGC_MAX_GAIN, config.AGCMaxGain)
+		if config.AGCTarget > 0 {
+			ctlInt32(preprocState, C.SPEEX_PREPROCESS_SET_AGC_TARGET, config.AGCTarget)
+		}
+	}
+
+	// Configure dereverberation (level and decay are floats in the Speex API)
+	dereverb := boolToInt32(config.EnableDereverb)
+	C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_DEREVERB, unsafe.Pointer(&dereverb))
+	if config.EnableDereverb {
+		level := C.float(config.DereverbLevel)
+		C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_DEREVERB_LEVEL, unsafe.Pointer(&level))
+		decay := C.float(config.DereverbDecay)
+		C.speex_preprocess_ctl(preprocState, C.SPEEX_PREPROCESS_SET_DEREVERB_DECAY, unsafe.Pointer(&decay))
+	}
+}
+
+// ctlInt32 sets an integer preprocessor control
+func ctlInt32(preprocState *C.SpeexPreprocessState, request C.int, value int) {
+	v := C.spx_int32_t(value)
+	C.speex_preprocess_ctl(preprocState, request, unsafe.Pointer(&v))
+}
+
+// boolToInt32 converts a flag to the integer value expected by Speex ctl requests
+func boolToInt32(b bool) C.spx_int32_t {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// VADResult is the voice activity of a preprocessed frame
+type VADResult struct {
+	Speech      bool // VAD decision (always true when VAD is disabled)
+	Probability int  // speech probability in percent
+}
+
+// ProcessFrame processes a frame with noise suppression
+func (ns *Preprocessor) ProcessFrame(inputFrame []int16) []int16 {
+	output, _ := ns.ProcessFrameVAD(inputFrame)
+	return output
+}
+
+// ProcessFrameVAD processes a frame with noise suppression and returns its voice activity
+func (ns *Preprocessor) ProcessFrameVAD(inputFrame []int16) ([]int16, VADResult) {
+	output := make([]int16, ns.frameSize)
+	vad, err := ns.ProcessFrameInto(output, inputFrame)
+	if err != nil {
+		return nil, VADResult{}
+	}
+	return output, vad
+}
+
+// ProcessFrameInto is ProcessFrameVAD writing into a caller-owned frame
+// dst may be inputFrame itself to process in place
+func (ns *Preprocessor) ProcessFrameInto(dst, inputFrame []int16) (VADResult, error) {
+	if len(dst) != ns.frameSize || len(inputFrame) != ns.frameSize {
+		return VADResult{}, errFrameSize
+	}
+
+	copy(dst, inputFrame)
+	outPtr := (*C.spx_int16_t)(unsafe.Pointer(&dst[0]))
+
+	// Apply preprocessing (noise suppression, VAD, AGC)
+	return runPreprocessor(ns.preprocState, outPtr), nil
+}
+
+// runPreprocessor preprocesses a frame in place and reads back the VAD decision and speech probability
+func runPreprocessor(preprocState *C.SpeexPreprocessState, frame *C.spx_int16_t) VADResult {
+	res := C.preprocess_run(preprocState, frame)
+	return VADResult{
+		Speech:      res.speech != 0,
+		Probability: int(res.prob),
+	}
+}
+
+// Reset discards the adapted noise, VAD and AGC state
+// Speex has no reset request, so the state is recreated with the same configuration
+func (ns *Preprocessor) Reset() {
+	if ns.preprocState == nil {
+		return
+	}
+	preprocState := C.speex_preprocess_state_init(C.int(ns.frameSize), C.int(ns.sampleRate))
+	if preprocState == nil {
+		return
+	}
+	configurePreprocessor(preprocState, ns.config)
+	C.speex_preprocess_state_destroy(ns.preprocState)
+	ns.preprocState = preprocState
+}
+
+// Destroy cleans up resources
+func (ns *Preprocessor) Destroy() {
+	if ns.preprocState != nil {
+		C.speex_preprocess_state_destroy(ns.preprocState)
+	}
+}
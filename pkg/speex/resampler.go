@@ -0,0 +1,132 @@
+package speex
+
+/*
+#cgo pkg-config: speexdsp
+#include <speex/speex_resampler.h>
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Resampler quality levels (0 = fastest, 10 = best)
+const (
+	ResamplerQualityMin     = C.SPEEX_RESAMPLER_QUALITY_MIN
+	ResamplerQualityMax     = C.SPEEX_RESAMPLER_QUALITY_MAX
+	ResamplerQualityVoIP    = C.SPEEX_RESAMPLER_QUALITY_VOIP
+	ResamplerQualityDefault = C.SPEEX_RESAMPLER_QUALITY_DEFAULT
+)
+
+// Resampler wraps the Speex sample-rate converter
+type Resampler struct {
+	state    *C.SpeexResamplerState
+	channels int
+}
+
+// NewResampler creates new Speex resampler instance
+// channels: number of interleaved channels
+// inRate, outRate: input and output sample rates in Hz
+// quality: conversion quality from ResamplerQualityMin to ResamplerQualityMax
+func NewResampler(channels, inRate, outRate, quality int) (*Resampler, error) {
+	if channels <= 0 || inRate <= 0 || outRate <= 0 {
+		return nil, errors.New("invalid parameters")
+	}
+	if quality < ResamplerQualityMin || quality > ResamplerQualityMax {
+		return nil, fmt.Errorf("resampler quality must be between %d and %d", ResamplerQualityMin, ResamplerQualityMax)
+	}
+
+	var errCode C.int
+	state := C.speex_resampler_init(C.spx_uint32_t(channels), C.spx_uint32_t(inRate), C.spx_uint32_t(outRate), C.int(quality), &errCode)
+	if state == nil {
+		return nil, fmt.Errorf("failed to create resampler state: %s", resamplerError(errCode))
+	}
+
+	return &Resampler{
+		state:    state,
+		channels: channels,
+	}, nil
+}
+
+// Process resamples interleaved input into interleaved output
+// Returns the number of samples per channel consumed from in and written to out;
+// unconsumed input must be passed again on the next call
+func (rs *Resampler) Process(in, out []int16) (consumed, produced int, err error) {
+	inLen := C.spx_uint32_t(len(in) / rs.channels)
+	outLen := C.spx_uint32_t(len(out) / rs.channels)
+	if inLen == 0 || outLen == 0 {
+		return 0, 0, nil
+	}
+
+	inPtr := (*C.spx_int16_t)(unsafe.Pointer(&in[0]))
+	outPtr := (*C.spx_int16_t)(unsafe.Pointer(&out[0]))
+
+	ret := C.speex_resampler_process_interleaved_int(rs.state, inPtr, &inLen, outPtr, &outLen)
+	if ret != C.RESAMPLER_ERR_SUCCESS {
+		return 0, 0, fmt.Errorf("resampling failed: %s", resamplerError(ret))
+	}
+	return int(inLen), int(outLen), nil
+}
+
+// SetRate changes the input and output sample rates
+func (rs *Resampler) SetRate(inRate, outRate int) error {
+	if inRate <= 0 || outRate <= 0 {
+		return errors.New("invalid parameters")
+	}
+	ret := C.speex_resampler_set_rate(rs.state, C.spx_uint32_t(inRate), C.spx_uint32_t(outRate))
+	if ret != C.RESAMPLER_ERR_SUCCESS {
+		return fmt.Errorf("failed to set rate: %s", resamplerError(ret))
+	}
+	return nil
+}
+
+// SetRateFrac changes the conversion ratio to ratioNum/ratioDen (input/output) while keeping
+// the nominal rates used for the filter cutoff; used for fine adjustments such as drift correction
+func (rs *Resampler) SetRateFrac(ratioNum, ratioDen, inRate, outRate int) error {
+	if ratioNum <= 0 || ratioDen <= 0 || inRate <= 0 || outRate <= 0 {
+		return errors.New("invalid parameters")
+	}
+	ret := C.speex_resampler_set_rate_frac(rs.state, C.spx_uint32_t(ratioNum), C.spx_uint32_t(ratioDen), C.spx_uint32_t(inRate), C.spx_uint32_t(outRate))
+	if ret != C.RESAMPLER_ERR_SUCCESS {
+		return fmt.Errorf("failed to set rate: %s", resamplerError(ret))
+	}
+	return nil
+}
+
+// InputLatency returns the resampler latency in input samples
+func (rs *Resampler) InputLatency() int {
+	return int(C.speex_resampler_get_input_latency(rs.state))
+}
+
+// SkipZeros makes the first output samples line up with the first input samples
+// by dropping the leading zeros caused by the filter latency
+func (rs *Resampler) SkipZeros() {
+	C.speex_resampler_skip_zeros(rs.state)
+}
+
+// Channels returns the number of interleaved channels
+func (rs *Resampler) Channels() int {
+	return rs.channels
+}
+
+// Reset clears the resampler history
+func (rs *Resampler) Reset() {
+	if rs.state != nil {
+		C.speex_resampler_reset_mem(rs.state)
+	}
+}
+
+// Destroy cleans up resources
+func (rs *Resampler) Destroy() {
+	if rs.state != nil {
+		C.speex_resampler_destroy(rs.state)
+		rs.state = nil
+	}
+}
+
+// resamplerError returns the Speex description of a resampler error code
+func resamplerError(code C.int) string {
+	return C.GoString(C.speex_resampler_strerror(code))
+}
@@ -0,0 +1,153 @@
+package speex
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func newTestResampler(t *testing.T, channels, inRate, outRate int) *Resampler {
+	t.Helper()
+	rs, err := NewResampler(channels, inRate, outRate, ResamplerQualityDefault)
+	if err != nil {
+		t.Fatalf("NewResampler() error = %v", err)
+	}
+	return rs
+}
+
+// noiseSamples returns n interleaved samples of white noise
+func noiseSamples(n int) []int16 {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(rng.NormFloat64() * 4000)
+	}
+	return samples
+}
+
+// resampleAll feeds in through rs in 160-sample chunks and returns all output samples
+func resampleAll(t *testing.T, rs *Resampler, in []int16) []int16 {
+	t.Helper()
+	channels := rs.Channels()
+	var out []int16
+	buf := make([]int16, 1024*channels)
+	for len(in) > 0 {
+		chunk := in[:min(len(in), 160*channels)]
+		consumed, produced, err := rs.Process(chunk, buf)
+		if err != nil {
+			t.Fatalf("Resampler.Process() error = %v", err)
+		}
+		if consumed == 0 && produced == 0 {
+			t.Fatal("Resampler.Process() made no progress")
+		}
+		in = in[consumed*channels:]
+		out = append(out, buf[:produced*channels]...)
+	}
+	return out
+}
+
+func TestNewResampler_Errors(t *testing.T) {
+	tests := []struct {
+		name                               string
+		channels, inRate, outRate, quality int
+	}{
+		{"zero channels", 0, 16000, 8000, ResamplerQualityDefault},
+		{"zero input rate", 1, 0, 8000, ResamplerQualityDefault},
+		{"negative output rate", 1, 16000, -8000, ResamplerQualityDefault},
+		{"quality below range", 1, 16000, 8000, ResamplerQualityMin - 1},
+		{"quality above range", 1, 16000, 8000, ResamplerQualityMax + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := NewResampler(tt.channels, tt.inRate, tt.outRate, tt.quality)
+			if err == nil {
+				rs.Destroy()
+				t.Fatal("NewResampler() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestResampler_ConversionLength(t *testing.T) {
+	tests := []struct {
+		name                      string
+		channels, inRate, outRate int
+	}{
+		{"downsample", 1, 16000, 8000},
+		{"upsample", 1, 8000, 16000},
+		{"non-integer ratio", 1, 44100, 16000},
+		{"stereo", 2, 16000, 8000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := newTestResampler(t, tt.channels, tt.inRate, tt.outRate)
+			defer rs.Destroy()
+			rs.SkipZeros()
+
+			latency := rs.InputLatency()
+			if latency <= 0 {
+				t.Fatalf("InputLatency() = %d, want > 0", latency)
+			}
+
+			// Flushing the latency with zeros makes the output cover the whole input
+			const frames = 3200
+			in := append(noiseSamples(frames*tt.channels), make([]int16, latency*tt.channels)...)
+			out := resampleAll(t, rs, in)
+			if len(out)%tt.channels != 0 {
+				t.Fatalf("output has %d samples, not a multiple of %d channels", len(out), tt.channels)
+			}
+
+			got := len(out) / tt.channels
+			want := frames * tt.outRate / tt.inRate
+			tolerance := (latency*tt.outRate+tt.inRate-1)/tt.inRate + 1
+			if got < want-tolerance || got > want+tolerance {
+				t.Errorf("output length = %d samples per channel, want %d ± %d", got, want, tolerance)
+			}
+		})
+	}
+}
+
+func TestResampler_SetRate(t *testing.T) {
+	rs := newTestResampler(t, 1, 16000, 8000)
+	defer rs.Destroy()
+
+	in := noiseSamples(3200)
+	before := len(resampleAll(t, rs, in))
+
+	if err := rs.SetRate(16000, 32000); err != nil {
+		t.Fatalf("Resampler.SetRate() error = %v", err)
+	}
+	after := len(resampleAll(t, rs, in))
+
+	// Switching from 1:2 to 2:1 mid-stream quadruples the output for the same input
+	tolerance := 2 * rs.InputLatency()
+	if after < 4*before-tolerance || after > 4*before+tolerance {
+		t.Errorf("output after SetRate = %d samples, want 4 × %d ± %d", after, before, tolerance)
+	}
+
+	if err := rs.SetRate(0, 8000); err == nil {
+		t.Error("Resampler.SetRate(0, 8000) error = nil, want error")
+	}
+}
+
+func TestResampler_Reset(t *testing.T) {
+	in := noiseSamples(1600)
+
+	fresh := newTestResampler(t, 1, 16000, 8000)
+	defer fresh.Destroy()
+	want := resampleAll(t, fresh, in)
+
+	rs := newTestResampler(t, 1, 16000, 8000)
+	defer rs.Destroy()
+	resampleAll(t, rs, noiseSamples(1000))
+	rs.Reset()
+	got := resampleAll(t, rs, in)
+
+	if len(got) != len(want) {
+		t.Fatalf("output after Reset = %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("output after Reset differs from a fresh resampler at sample %d: %d != %d", i, got[i], want[i])
+		}
+	}
+}
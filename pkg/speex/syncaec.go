@@ -0,0 +1,70 @@
+package speex
+
+import (
+	"errors"
+	"sync"
+)
+
+var errDestroyed = errors.New("echo canceller destroyed")
+
+// SyncAEC serializes access to an AEC so that Playback and Capture can be called
+// from separate audio callbacks
+type SyncAEC struct {
+	mu  sync.Mutex
+	aec *AEC
+}
+
+// NewSyncAEC wraps an AEC; the wrapper takes ownership and destroys it in Destroy
+func NewSyncAEC(aec *AEC) *SyncAEC {
+	return &SyncAEC{aec: aec}
+}
+
+// Playback queues a frame sent to the speaker (see AEC.Playback)
+func (s *SyncAEC) Playback(speakerFrame []int16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aec == nil {
+		return errDestroyed
+	}
+	return s.aec.Playback(speakerFrame)
+}
+
+// Capture cancels the echo from a captured frame (see AEC.Capture)
+// Returns nil after Destroy
+func (s *SyncAEC) Capture(micFrame []int16) []int16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aec == nil {
+		return nil
+	}
+	return s.aec.Capture(micFrame)
+}
+
+// CaptureInto cancels the echo from a captured frame into a caller-owned frame (see AEC.CaptureInto)
+func (s *SyncAEC) CaptureInto(dst, micFrame []int16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aec == nil {
+		return errDestroyed
+	}
+	return s.aec.CaptureInto(dst, micFrame)
+}
+
+// Reset resets the echo canceller state and drops the queued playback frames
+func (s *SyncAEC) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aec != nil {
+		s.aec.Reset()
+	}
+}
+
+// Destroy cleans up resources; later calls are no-ops
+func (s *SyncAEC) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aec != nil {
+		s.aec.Destroy()
+		s.aec = nil
+	}
+}
@@ -0,0 +1,63 @@
+package speex
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSyncAEC_Destroyed(t *testing.T) {
+	s := NewSyncAEC(newTestAEC(t))
+	s.Destroy()
+	s.Destroy() // later calls are no-ops
+
+	frame := make([]int16, testFrameSize)
+	if err := s.Playback(frame); !errors.Is(err, errDestroyed) {
+		t.Errorf("Playback() error = %v, want %v", err, errDestroyed)
+	}
+	if out := s.Capture(frame); out != nil {
+		t.Errorf("Capture() = %d samples, want nil", len(out))
+	}
+	if err := s.CaptureInto(frame, frame); !errors.Is(err, errDestroyed) {
+		t.Errorf("CaptureInto() error = %v, want %v", err, errDestroyed)
+	}
+	s.Reset()
+}
+
+func TestSyncAEC_Concurrent(t *testing.T) {
+	s := NewSyncAEC(newTestAEC(t))
+	defer s.Destroy()
+
+	const frames = 200
+	mic, speaker := noiseFrames(frames)
+
+	// Playback and capture run in separate goroutines like audio device callbacks
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*frames)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for _, frame := range speaker {
+			if err := s.Playback(frame); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		out := make([]int16, testFrameSize)
+		for i, frame := range mic {
+			if err := s.CaptureInto(out, frame); err != nil {
+				errs <- err
+			}
+			if i%50 == 0 {
+				s.Reset()
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent call error = %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package stage
+
+// Built-in stages wrapping the SpeexDSP echo canceller and preprocessor and simple DSP
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"open_tool_speex/internal/dsp"
+	"open_tool_speex/pkg/audio"
+	"open_tool_speex/pkg/speex"
+	"open_tool_speex/pkg/types"
+)
+
+func init() {
+	Register(types.StageAEC, newAEC)
+	Register(types.StageNS, newNS)
+	Register(types.StageAGC, newAGC)
+	Register(types.StageHPF, newHighPass)
+	Register(types.StageGain, newGain)
+	Register(types.StageALaw, newALaw)
+}
+
+// AEC cancels the echo of the speaker reference
+type AEC struct {
+	canceller *speex.AEC
+	out       []int16
+}
+
+// NewAEC wraps an echo canceller; the stage takes ownership and destroys it in Close
+func NewAEC(canceller *speex.AEC) *AEC {
+	return &AEC{canceller: canceller}
+}
+
+func newAEC(cfg *types.Config, prev []Stage) (Stage, error) {
+	canceller, err := speex.NewAECWithConfig(cfg.FrameSize, cfg.FilterLen, cfg.SampleRate, cfg.NS)
+	if err != nil {
+		return nil, err
+	}
+	return NewAEC(canceller), nil
+}
+
+// Name returns "aec"
+func (s *AEC) Name() string { return types.StageAEC }
+
+// Process removes the echo of f.Speaker from f.Mic
+func (s *AEC) Process(f *Frame) error {
+	if f.Speaker == nil {
+		return errors.New("aec stage needs a speaker reference")
+	}
+	s.out = frameBuffer(s.out, len(f.Mic))
+	if err := s.canceller.ProcessFrameEchoOnlyInto(s.out, f.Mic, f.Speaker); err != nil {
+		return fmt.Errorf("AEC processing failed: %w", err)
+	}
+	f.Mic = s.out
+	return nil
+}
+
+// Reset resets the echo canceller
+func (s *AEC) Reset() { s.canceller.Reset() }
+
+// Close destroys the echo canceller
+func (s *AEC) Close() error {
+	s.canceller.Destroy()
+	return nil
+}
+
+// Canceller returns the wrapped echo canceller
+func (s *AEC) Canceller() *speex.AEC { return s.canceller }
+
+// NS runs a standalone Speex preprocessor (noise suppression, VAD, AGC, dereverb)
+type NS struct {
+	preprocessor *speex.Preprocessor
+	reportVAD    bool
+	out          []int16
+}
+
+// NewNS wraps a preprocessor; the stage takes ownership and destroys it in Close
+// If reportVAD is set the stage stores its voice activity in the frame
+func NewNS(preprocessor *speex.Preprocessor, reportVAD bool) *NS {
+	return &NS{preprocessor: preprocessor, reportVAD: reportVAD}
+}
+
+// newNS creates the ns stage, which uses the preprocessor linked to the echo canceller
+// when it directly follows an aec stage (residual echo suppression)
+func newNS(cfg *types.Config, prev []Stage) (Stage, error) {
+	if len(prev) > 0 {
+		if aec, ok := prev[len(prev)-1].(*AEC); ok {
+			return &linkedNS{canceller: aec.canceller}, nil
+		}
+	}
+	preprocessor, err := speex.NewPreprocessorWithConfig(cfg.FrameSize, cfg.SampleRate, cfg.NS)
+	if err != nil {
+		return nil, err
+	}
+	return NewNS(preprocessor, true), nil
+}
+
+// newAGC creates a preprocessor stage with only automatic gain control enabled
+func newAGC(cfg *types.Config, prev []Stage) (Stage, error) {
+	ns := cfg.NS
+	ns.EnableDenoise = false
+	ns.EnableVAD = false
+	ns.EnableDereverb = false
+	ns.EnableAGC = true
+	preprocessor, err := speex.NewPreprocessorWithConfig(cfg.FrameSize, cfg.SampleRate, ns)
+	if err != nil {
+		return nil, err
+	}
+	return &agc{NS: NewNS(preprocessor, false)}, nil
+}
+
+// Name returns "ns"
+func (s *NS) Name() string { return types.StageNS }
+
+// Process preprocesses f.Mic
+func (s *NS) Process(f *Frame) error {
+	s.out = frameBuffer(s.out, len(f.Mic))
+	vad, err := s.preprocessor.ProcessFrameInto(s.out, f.Mic)
+	if err != nil {
+		return fmt.Errorf("NS processing failed: %w", err)
+	}
+	f.Mic = s.out
+	if s.reportVAD {
+		f.VAD = vad
+	}
+	return nil
+}
+
+// Reset recreates the preprocessor state
+func (s *NS) Reset() { s.preprocessor.Reset() }
+
+// Close destroys the preprocessor
+func (s *NS) Close() error {
+	s.preprocessor.Destroy()
+	return nil
+}
+
+// agc is the NS stage registered as "agc"
+type agc struct {
+	*NS
+}
+
+// Name returns "agc"
+func (s *agc) Name() string { return types.StageAGC }
+
+// linkedNS runs the preprocessor of the preceding echo canceller, which also suppresses residual echo
+// The echo canceller owns the preprocessor
+type linkedNS struct {
+	canceller *speex.AEC
+	out       []int16
+}
+
+func (s *linkedNS) Name() string { return types.StageNS }
+
+func (s *linkedNS) Process(f *Frame) error {
+	s.out = frameBuffer(s.out, len(f.Mic))
+	vad, err := s.canceller.PreprocessInto(s.out, f.Mic)
+	if err != nil {
+		return fmt.Errorf("AEC processing failed: %w", err)
+	}
+	f.Mic = s.out
+	f.VAD = vad
+	return nil
+}
+
+// Reset recreates the preprocessor of the echo canceller, which the aec stage does not reset
+func (s *linkedNS) Reset() { s.canceller.ResetPreprocessor() }
+
+func (s *linkedNS) Close() error { return nil }
+
+// HighPass removes low-frequency rumble and DC offset
+type HighPass struct {
+	filter *dsp.Biquad
+	out    []int16
+}
+
+// NewHighPass creates a Butterworth high-pass stage
+func NewHighPass(cutoffHz float64, sampleRate int) *HighPass {
+	return &HighPass{filter: dsp.NewHighPass(cutoffHz, sampleRate)}
+}
+
+func newHighPass(cfg *types.Config, prev []Stage) (Stage, error) {
+	if cfg.HighPassHz <= 0 || cfg.HighPassHz >= float64(cfg.SampleRate)/2 {
+		return nil, errors.New("cutoff must be between 0 and half the sample rate")
+	}
+	return NewHighPass(cfg.HighPassHz, cfg.SampleRate), nil
+}
+
+// Name returns "hpf"
+func (s *HighPass) Name() string { return types.StageHPF }
+
+// Process filters f.Mic
+func (s *HighPass) Process(f *Frame) error {
+	s.out = frameBuffer(s.out, len(f.Mic))
+	s.filter.Process(s.out, f.Mic)
+	f.Mic = s.out
+	return nil
+}
+
+// Reset clears the filter state
+func (s *HighPass) Reset() { s.filter.Reset() }
+
+// Close does nothing
+func (s *HighPass) Close() error { return nil }
+
+// Gain applies a fixed gain with saturation
+type Gain struct {
+	factor float64
+	out    []int16
+}
+
+// NewGain creates a gain stage
+func NewGain(gainDB float64) *Gain {
+	return &Gain{factor: math.Pow(10, gainDB/20)}
+}
+
+func newGain(cfg *types.Config, prev []Stage) (Stage, error) {
+	return NewGain(cfg.GainDB), nil
+}
+
+// Name returns "gain"
+func (s *Gain) Name() string { return types.StageGain }
+
+// Process scales f.Mic
+func (s *Gain) Process(f *Frame) error {
+	s.out = frameBuffer(s.out, len(f.Mic))
+	for i, v := range f.Mic {
+		s.out[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(float64(v)*s.factor))))
+	}
+	f.Mic = s.out
+	return nil
+}
+
+// Reset does nothing
+func (s *Gain) Reset() {}
+
+// Close does nothing
+func (s *Gain) Close() error { return nil }
+
+// ALaw passes f.Mic through A-law encoding and decoding to expose the companding loss
+type ALaw struct {
+	encoded []byte
+	out     []int16
+}
+
+// NewALaw creates an A-law round-trip stage
+func NewALaw() *ALaw {
+	return &ALaw{}
+}
+
+func newALaw(cfg *types.Config, prev []Stage) (Stage, error) {
+	return NewALaw(), nil
+}
+
+// Name returns "alaw"
+func (s *ALaw) Name() string { return types.StageALaw }
+
+// Process encodes and decodes f.Mic
+func (s *ALaw) Process(f *Frame) error {
+	if len(s.encoded) != len(f.Mic) {
+		s.encoded = make([]byte, len(f.Mic))
+	}
+	s.out = frameBuffer(s.out, len(f.Mic))
+	audio.PCM16BufferToAlaw(f.Mic, s.encoded)
+	audio.AlawBufferToPCM16(s.encoded, s.out)
+	f.Mic = s.out
+	return nil
+}
+
+// Reset does nothing
+func (s *ALaw) Reset() {}
+
+// Close does nothing
+func (s *ALaw) Close() error { return nil }
@@ -0,0 +1,160 @@
+// Package stage provides frame processing stages and the chain that runs them in order
+//
+// Lifecycle: a Chain owns its stages and the SpeexDSP state inside them; Close releases
+// every stage once, after which neither the chain nor its stages may be used.
+//
+// Ownership: a stage writes its output into a buffer it owns and points Frame.Mic at it;
+// the buffer is overwritten by the next Process call, so copy Frame.Mic to keep it. Stages
+// do not modify or keep the frames they are given and are not safe for concurrent use.
+// Processing a frame allocates nothing once the stage buffers have grown to the frame size.
+package stage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"open_tool_speex/pkg/speex"
+	"open_tool_speex/pkg/types"
+)
+
+// Frame carries one frame through a chain
+type Frame struct {
+	Mic     []int16         // Signal being processed; each stage points it at its output buffer
+	Speaker []int16         // Far-end reference of the frame (nil without one)
+	VAD     speex.VADResult // Voice activity reported by the last ns stage
+}
+
+// Stage processes the frames of a chain
+type Stage interface {
+	// Name returns the registry name of the stage
+	Name() string
+	// Process processes one frame
+	Process(f *Frame) error
+	// Reset discards the adapted state
+	Reset()
+	// Close releases the resources of the stage
+	Close() error
+}
+
+// Factory creates a stage from the configuration; prev holds the stages before it in the chain
+type Factory func(cfg *types.Config, prev []Stage) (Stage, error)
+
+var (
+	factoryMu sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register adds a stage factory under a name
+// Registering a name twice replaces the previous factory
+func Register(name string, factory Factory) {
+	factoryMu.Lock()
+	defer factoryMu.Unlock()
+	factories[strings.ToLower(name)] = factory
+}
+
+// Lookup returns the factory registered under a name (case-insensitive)
+func Lookup(name string) (Factory, error) {
+	factoryMu.RLock()
+	defer factoryMu.RUnlock()
+
+	if factory, ok := factories[strings.ToLower(name)]; ok {
+		return factory, nil
+	}
+	return nil, fmt.Errorf("unknown stage %q (supported: %s)", name, strings.Join(namesLocked(), ", "))
+}
+
+// Names returns the registered stage names in sorted order
+func Names() []string {
+	factoryMu.RLock()
+	defer factoryMu.RUnlock()
+	return namesLocked()
+}
+
+// namesLocked returns the sorted names; the caller holds factoryMu
+func namesLocked() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Chain runs an ordered list of stages
+type Chain struct {
+	stages []Stage
+}
+
+// NewChain creates the named stages in order
+// An empty list gives a chain that passes frames through unchanged
+func NewChain(names []string, cfg *types.Config) (*Chain, error) {
+	chain := &Chain{}
+	for _, name := range names {
+		factory, err := Lookup(name)
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+		stage, err := factory(cfg, chain.stages)
+		if err != nil {
+			chain.Close()
+			return nil, fmt.Errorf("failed to initialize %s stage: %w", name, err)
+		}
+		chain.stages = append(chain.stages, stage)
+	}
+	return chain, nil
+}
+
+// Process runs a frame through all stages
+func (c *Chain) Process(f *Frame) error {
+	for _, stage := range c.stages {
+		if err := stage.Process(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset resets every stage
+func (c *Chain) Reset() {
+	for _, stage := range c.stages {
+		stage.Reset()
+	}
+}
+
+// Close closes the stages in reverse order and returns the first error
+func (c *Chain) Close() error {
+	var firstErr error
+	for i := len(c.stages) - 1; i >= 0; i-- {
+		if err := c.stages[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.stages = nil
+	return firstErr
+}
+
+// frameBuffer returns buf resized to n samples, reusing its storage when large enough
+func frameBuffer(buf []int16, n int) []int16 {
+	if cap(buf) < n {
+		return make([]int16, n)
+	}
+	return buf[:n]
+}
+
+// Stages returns the stages in order
+func (c *Chain) Stages() []Stage {
+	return c.stages
+}
+
+// Canceller returns the echo canceller of the first aec stage, or nil
+func (c *Chain) Canceller() *speex.AEC {
+	for _, stage := range c.stages {
+		if aec, ok := stage.(*AEC); ok {
+			return aec.canceller
+		}
+	}
+	return nil
+}
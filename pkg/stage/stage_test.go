@@ -0,0 +1,258 @@
+package stage
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"open_tool_speex/pkg/types"
+)
+
+func testConfig() *types.Config {
+	cfg := types.DefaultConfig()
+	cfg.FilterLen = 1600
+	return &cfg
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "aec", input: "aec"},
+		{name: "upper case", input: "NS"},
+		{name: "hpf", input: "hpf"},
+		{name: "unknown", input: "reverb", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Lookup(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Lookup(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		stages  []string
+		want    []string // Names reported by the created stages
+		linked  bool     // ns stage uses the preprocessor of the echo canceller
+		wantErr bool
+	}{
+		{name: "empty", stages: nil, want: nil},
+		{name: "aec then ns", stages: []string{"aec", "ns"}, want: []string{"aec", "ns"}, linked: true},
+		{name: "ns then aec", stages: []string{"ns", "aec"}, want: []string{"ns", "aec"}},
+		{name: "ns after hpf", stages: []string{"aec", "hpf", "ns"}, want: []string{"aec", "hpf", "ns"}},
+		{name: "all", stages: []string{"hpf", "aec", "ns", "agc", "gain", "alaw"}, want: []string{"hpf", "aec", "ns", "agc", "gain", "alaw"}, linked: true},
+		{name: "unknown", stages: []string{"hpf", "reverb"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := NewChain(tt.stages, testConfig())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewChain(%v) error = %v, wantErr %v", tt.stages, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			defer chain.Close()
+
+			var names []string
+			linked := false
+			for _, stage := range chain.Stages() {
+				names = append(names, stage.Name())
+				if _, ok := stage.(*linkedNS); ok {
+					linked = true
+				}
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("stages = %v, want %v", names, tt.want)
+			}
+			if linked != tt.linked {
+				t.Errorf("linked ns = %v, want %v", linked, tt.linked)
+			}
+			if hasAEC := slices.Contains(tt.stages, "aec"); (chain.Canceller() != nil) != hasAEC {
+				t.Errorf("Canceller() = %v, want one: %v", chain.Canceller(), hasAEC)
+			}
+		})
+	}
+}
+
+func TestChain_Process(t *testing.T) {
+	cfg := testConfig()
+	cfg.GainDB = 6
+
+	chain, err := NewChain([]string{"gain"}, cfg)
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+	defer chain.Close()
+
+	mic := []int16{0, 1000, -1000, 20000, -20000}
+	frame := Frame{Mic: mic}
+	if err := chain.Process(&frame); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	want := []int16{0, 1995, -1995, 32767, -32768}
+	if !slices.Equal(frame.Mic, want) {
+		t.Errorf("Mic = %v, want %v", frame.Mic, want)
+	}
+	if mic[1] != 1000 {
+		t.Errorf("input frame was modified")
+	}
+}
+
+func TestChain_Reset(t *testing.T) {
+	cfg := testConfig()
+	cfg.NS.EnableVAD = true
+	cfg.NS.EnableAGC = true
+
+	// Speech-like noise at a rising level adapts the echo canceller, noise estimate and AGC
+	rng := rand.New(rand.NewSource(3))
+	var mics, speakers [][]int16
+	for f := 0; f < 50; f++ {
+		mic := make([]int16, cfg.FrameSize)
+		speaker := make([]int16, cfg.FrameSize)
+		for i := range mic {
+			speaker[i] = int16(rng.NormFloat64() * 3000)
+			mic[i] = speaker[i]/2 + int16(rng.NormFloat64()*float64(100*(f+1)))
+		}
+		mics = append(mics, mic)
+		speakers = append(speakers, speaker)
+	}
+	run := func(chain *Chain) []int16 {
+		var output []int16
+		for i := range mics {
+			frame := Frame{Mic: mics[i], Speaker: speakers[i]}
+			if err := chain.Process(&frame); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			output = append(output, frame.Mic...)
+		}
+		return output
+	}
+
+	for _, stages := range [][]string{{"aec", "ns"}, {"ns", "aec"}, {"hpf", "agc"}} {
+		fresh, err := NewChain(stages, cfg)
+		if err != nil {
+			t.Fatalf("NewChain(%v) error = %v", stages, err)
+		}
+		want := run(fresh)
+		fresh.Close()
+
+		// After Reset the chain behaves like a new one, including the preprocessor linked to the AEC
+		chain, err := NewChain(stages, cfg)
+		if err != nil {
+			t.Fatalf("NewChain(%v) error = %v", stages, err)
+		}
+		run(chain)
+		chain.Reset()
+		if got := run(chain); !slices.Equal(got, want) {
+			t.Errorf("chain %v output after Reset differs from a new chain", stages)
+		}
+		chain.Close()
+	}
+}
+
+func TestChain_ProcessAECWithoutSpeaker(t *testing.T) {
+	cfg := testConfig()
+	chain, err := NewChain([]string{"aec"}, cfg)
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+	defer chain.Close()
+
+	frame := Frame{Mic: make([]int16, cfg.FrameSize)}
+	if err := chain.Process(&frame); err == nil {
+		t.Errorf("Process() without speaker succeeded")
+	}
+}
+
+// failingStage fails every frame
+type failingStage struct{ closed *bool }
+
+func (s failingStage) Name() string         { return "fail" }
+func (s failingStage) Process(*Frame) error { return errors.New("failed") }
+func (s failingStage) Reset()               {}
+func (s failingStage) Close() error         { *s.closed = true; return nil }
+
+func TestRegister(t *testing.T) {
+	closed := false
+	Register("fail", func(cfg *types.Config, prev []Stage) (Stage, error) {
+		return failingStage{closed: &closed}, nil
+	})
+	defer func() {
+		factoryMu.Lock()
+		delete(factories, "fail")
+		factoryMu.Unlock()
+	}()
+
+	if !slices.Contains(Names(), "fail") {
+		t.Fatalf("Names() = %v, missing registered stage", Names())
+	}
+	chain, err := NewChain([]string{"gain", "fail"}, testConfig())
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+	if err := chain.Process(&Frame{Mic: make([]int16, 4)}); err == nil {
+		t.Errorf("Process() error = nil, want the stage error")
+	}
+	if err := chain.Close(); err != nil || !closed {
+		t.Errorf("Close() error = %v, closed = %v", err, closed)
+	}
+}
+
+func TestChain_ProcessAllocs(t *testing.T) {
+	cfg := testConfig()
+	cfg.GainDB = 3
+	chain, err := NewChain([]string{"hpf", "aec", "ns", "agc", "gain", "alaw"}, cfg)
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+	defer chain.Close()
+
+	mic := make([]int16, cfg.FrameSize)
+	speaker := make([]int16, cfg.FrameSize)
+	frame := &Frame{}
+	allocs := testing.AllocsPerRun(100, func() {
+		*frame = Frame{Mic: mic, Speaker: speaker}
+		if err := chain.Process(frame); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Process() allocates %.1f times per frame, want 0", allocs)
+	}
+}
+
+func BenchmarkChain_Process(b *testing.B) {
+	cfg := testConfig()
+	for _, mode := range []types.ProcessingMode{types.ModeNSOnly, types.ModeAECOnly, types.ModeNSFirst, types.ModeAECFirst} {
+		b.Run(mode.String(), func(b *testing.B) {
+			chain, err := NewChain(mode.Chain(), cfg)
+			if err != nil {
+				b.Fatalf("NewChain() error = %v", err)
+			}
+			defer chain.Close()
+
+			mic := make([]int16, cfg.FrameSize)
+			speaker := make([]int16, cfg.FrameSize)
+			frame := &Frame{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				*frame = Frame{Mic: mic, Speaker: speaker}
+				if err := chain.Process(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,36 @@
 package types
 
+import (
+	"io"
+	"math"
+	"slices"
+)
+
 // NSConfig holds noise suppression configuration parameters
 type NSConfig struct {
+	EnableDenoise bool    // Enable noise suppression (denoise)
 	NoiseSuppress float64 // Noise suppression level in dB
 	EnableVAD     bool    // Enable Voice Activity Detection
 	VADProbStart  int     // VAD probability threshold for speech start (0-100)
 	VADProbCont   int     // VAD probability threshold for speech continue (0-100)
 	EnableAGC     bool    // Enable Automatic Gain Control
 	AGCLevel      float64 // AGC target RMS level
+
+	// AGC dynamics (DefaultConfig has the Speex defaults)
+	AGCIncrement int // Maximal gain increase in dB/second
+	AGCDecrement int // Maximal gain decrease in dB/second (negative)
+	AGCMaxGain   int // Maximal gain in dB (0 = no gain)
+	AGCTarget    int // Integer AGC target level overriding AGCLevel (0 = use AGCLevel)
+
+	// Dereverberation (DefaultConfig has the Speex defaults)
+	EnableDereverb bool    // Enable dereverberation
+	DereverbLevel  float64 // Dereverberation level (0-1)
+	DereverbDecay  float64 // Dereverberation decay (0-1)
+
+	// Residual echo suppression of the preprocessor linked to the echo canceller
+	// (dB, -100 to 0; 0 disables the suppression, DefaultConfig has the Speex defaults)
+	EchoSuppress       int // Attenuation of residual echo
+	EchoSuppressActive int // Attenuation of residual echo while the near end is active
 }
 
 // ProcessingMode represents the audio processing mode
@@ -42,6 +65,69 @@ func (m ProcessingMode) String() string {
 	}
 }
 
+// Chain returns the processing chain equivalent to the mode
+func (m ProcessingMode) Chain() []string {
+	switch m {
+	case ModeBypass:
+		return nil
+	case ModeTestAlaw:
+		return []string{StageALaw}
+	case ModeNSOnly:
+		return []string{StageNS}
+	case ModeAECOnly:
+		return []string{StageAEC}
+	case ModeNSFirst:
+		return []string{StageNS, StageAEC}
+	default:
+		return []string{StageAEC, StageNS}
+	}
+}
+
+// Stage names of a processing chain (implemented by pkg/stage)
+const (
+	StageAEC  = "aec"  // echo cancellation
+	StageNS   = "ns"   // Speex preprocessor (linked to the echo canceller right after aec)
+	StageAGC  = "agc"  // automatic gain control only
+	StageHPF  = "hpf"  // high-pass filter
+	StageGain = "gain" // fixed gain
+	StageALaw = "alaw" // A-law encode/decode round trip
+)
+
+// SampleFormat names the sample encoding of an audio stream
+// Values are codec names registered in pkg/audio; the constants cover the built-in codecs
+type SampleFormat string
+
+const (
+	FormatALaw  SampleFormat = "alaw" // default
+	FormatMuLaw SampleFormat = "mulaw"
+	FormatPCM16 SampleFormat = "pcm16" // signed 16-bit little-endian
+	FormatS16BE SampleFormat = "s16be"
+	FormatF32LE SampleFormat = "f32le"
+)
+
+// String returns the string representation of SampleFormat
+func (f SampleFormat) String() string {
+	return string(f)
+}
+
+// VADGate selects how frames without speech are written to the output
+type VADGate string
+
+const (
+	GateOff     VADGate = ""        // default: output unchanged
+	GateSilence VADGate = "silence" // replace with digital silence (A-law 0xD5)
+	GateNoise   VADGate = "noise"   // replace with comfort noise
+	GateDrop    VADGate = "drop"    // remove from the output
+)
+
+// String returns the string representation of VADGate
+func (g VADGate) String() string {
+	if g == GateOff {
+		return "off"
+	}
+	return string(g)
+}
+
 // Config holds the complete processing configuration
 type Config struct {
 	// File paths
@@ -49,38 +135,199 @@ type Config struct {
 	SpeakerFile string
 	OutputFile  string
 
+	// Further mono files of a multi-channel AEC (MicFile and SpeakerFile are channel 0)
+	ExtraMicFiles     []string
+	ExtraSpeakerFiles []string
+
+	// Interleaved input carrying mic and speaker reference (replaces MicFile/SpeakerFile)
+	InputFile      string
+	InputChannels  int  // Channel count of a raw interleaved input (WAV uses its header)
+	MicChannel     int  // Input channel holding the microphone signal
+	SpeakerChannel int  // Input channel holding the speaker reference
+	StereoOutput   bool // Write processed mic (left) and original reference (right)
+
+	// Further input channels of a multi-channel AEC
+	ExtraMicChannels     []int
+	ExtraSpeakerChannels []int
+
 	// Processing mode
 	Mode ProcessingMode
 
+	// Processing chain of stage names (empty = the chain of Mode)
+	Chain      []string
+	HighPassHz float64 // Cutoff of the hpf stage
+	GainDB     float64 // Gain of the gain stage
+
+	// Sample formats (raw files; WAV inputs use the format from their header)
+	MicFormat     SampleFormat
+	SpeakerFormat SampleFormat
+	OutputFormat  SampleFormat
+
+	// Stream sample rates in Hz (0 = SampleRate; WAV inputs use their header)
+	MicRate         int
+	SpeakerRate     int
+	OutputRate      int
+	ResampleQuality int // SpeexDSP resampler quality (0-10)
+
 	// Processing parameters
 	SampleRate     int
 	FrameSize      int
 	EchoTailMs     int
 	FilterLen      int
 	ProgressSec    float64
-	UsePrevSpeaker bool
+	UsePrevSpeaker bool // Shorthand for a speaker delay of one frame
+
+	// Fixed speaker delay relative to the mic (negative = advance by delaying the mic)
+	SpeakerDelay   int     // Delay in samples
+	SpeakerDelayMs float64 // Delay in milliseconds (overrides SpeakerDelay if non-zero)
+
+	// Automatic speaker-to-mic delay alignment
+	AutoDelay      bool    // Estimate the echo path delay and shift the speaker stream
+	MaxDelayMs     int     // Largest delay searched in milliseconds
+	DelayWindowSec float64 // Analysis window in seconds
+	DelayUpdateSec float64 // Re-estimation interval in seconds (0 = estimate once)
+
+	// Clock-drift compensation between mic and speaker (uses MaxDelayMs and DelayWindowSec)
+	DriftCompensation bool    // Measure drift and resample the speaker stream to cancel it
+	DriftUpdateSec    float64 // Drift measurement interval in seconds
+
+	// Echo metrics outputs (empty = disabled; only modes with echo cancellation)
+	MetricsFile string // JSON summary
+	MetricsCSV  string // Per-frame CSV
+
+	// Impulse response of the adaptive filter (empty = disabled; only modes with echo cancellation)
+	ImpulseResponseFile string  // CSV, or WAV if the name ends in .wav
+	ImpulseResponseSec  float64 // Snapshot interval in seconds (0 = end of processing only)
+
+	// Voice activity timeline outputs (empty = disabled; only modes with a preprocessor)
+	VADCSV    string // Per-frame VAD decision and speech probability as CSV
+	VADJSON   string // Per-frame timeline and speech segments as JSON
+	VADLabels string // Speech segments as an Audacity label track
+
+	// Speech segmentation: one output file per VAD speech segment (empty dir = disabled)
+	SegmentDir      string // Directory for segment files and the manifest
+	SegmentPadMs    int    // Padding kept before and after the speech
+	SegmentMinMs    int    // Shortest kept segment (speech without padding)
+	SegmentMaxGapMs int    // Longest silence kept inside a segment
+
+	// VAD-gated output
+	VADGate        VADGate // Treatment of frames without speech
+	ComfortNoiseDB float64 // Comfort noise level in dBFS (GateNoise)
+	VADEditList    string  // CSV mapping kept output ranges to source time (GateDrop)
+
+	// Comfort noise matched to the background of the mic signal (GateNoise uses its spectral shape)
+	ComfortFill          bool    // Fill suppressed output frames up to the background level
+	ComfortNoiseOffsetDB float64 // Level relative to the background after noise suppression in dB
 
 	// Noise suppression configuration
 	NS NSConfig
 }
 
+// StdioPath is the file name standing for standard input (inputs) or standard output (output)
+const StdioPath = "-"
+
+// Streams supplies the audio of a run in place of the files named in Config
+// Inputs are WAV if they start with a RIFF/WAVE header and raw samples in the configured
+// formats otherwise; multi-channel AEC needs files
+type Streams struct {
+	Mic       io.Reader // Mic input
+	Speaker   io.Reader // Speaker reference (nil in modes without one)
+	Input     io.Reader // Interleaved input replacing Mic and Speaker (layout from InputChannels, MicChannel, ...)
+	Output    io.Writer // Processed output
+	OutputWAV bool      // Write the output as WAV instead of raw samples
+}
+
 // DefaultConfig returns a default configuration
 func DefaultConfig() Config {
 	return Config{
-		OutputFile:     "output.alaw",
-		Mode:           ModeAECFirst,
-		SampleRate:     16000,
-		FrameSize:      320,
-		EchoTailMs:     200,
-		ProgressSec:    16.0,
-		UsePrevSpeaker: false,
+		OutputFile:      "output.alaw",
+		InputChannels:   2,
+		MicChannel:      0,
+		SpeakerChannel:  1,
+		Mode:            ModeAECFirst,
+		MicFormat:       FormatALaw,
+		SpeakerFormat:   FormatALaw,
+		OutputFormat:    FormatALaw,
+		ResampleQuality: 4,
+		SampleRate:      16000,
+		FrameSize:       320,
+		EchoTailMs:      200,
+		ProgressSec:     16.0,
+		UsePrevSpeaker:  false,
+		MaxDelayMs:      500,
+		DelayWindowSec:  2.0,
+		DriftUpdateSec:  2.0,
+		SegmentPadMs:    200,
+		SegmentMinMs:    300,
+		SegmentMaxGapMs: 500,
+		ComfortNoiseDB:  -60,
+		HighPassHz:      100,
 		NS: NSConfig{
-			NoiseSuppress: -15.0,
-			EnableVAD:     false,
-			VADProbStart:  80,
-			VADProbCont:   65,
-			EnableAGC:     false,
-			AGCLevel:      30000.0,
+			EnableDenoise:      true,
+			NoiseSuppress:      -15.0,
+			EnableVAD:          false,
+			VADProbStart:       80,
+			VADProbCont:        65,
+			EnableAGC:          false,
+			AGCLevel:           30000.0,
+			AGCIncrement:       12,
+			AGCDecrement:       -40,
+			AGCMaxGain:         30,
+			EchoSuppress:       -40,
+			EchoSuppressActive: -15,
 		},
 	}
 }
+
+// SpeakerDelaySamples returns the fixed speaker delay in samples (negative = advance),
+// resolving SpeakerDelayMs and the one-frame UsePrevSpeaker shorthand
+func (c *Config) SpeakerDelaySamples() int {
+	switch {
+	case c.UsePrevSpeaker:
+		return c.FrameSize
+	case c.SpeakerDelayMs != 0:
+		return int(math.Round(c.SpeakerDelayMs * float64(c.SampleRate) / 1000))
+	default:
+		return c.SpeakerDelay
+	}
+}
+
+// Stages returns the processing chain: Chain if set, otherwise the chain of Mode
+func (c *Config) Stages() []string {
+	if len(c.Chain) > 0 {
+		return c.Chain
+	}
+	return c.Mode.Chain()
+}
+
+// HasStage reports whether the processing chain contains a stage
+// Names are compared as given; pipeline.New and the CLI lower-case Chain
+func (c *Config) HasStage(name string) bool {
+	return slices.Contains(c.Stages(), name)
+}
+
+// NeedsVAD reports whether any output uses the per-frame voice activity decision
+func (c *Config) NeedsVAD() bool {
+	return c.VADCSV != "" || c.VADJSON != "" || c.VADLabels != "" || c.SegmentDir != "" || c.VADGate != GateOff
+}
+
+// MicChannels returns the number of mic channels
+func (c *Config) MicChannels() int {
+	if c.InputFile != "" {
+		return 1 + len(c.ExtraMicChannels)
+	}
+	return 1 + len(c.ExtraMicFiles)
+}
+
+// SpeakerChannels returns the number of speaker reference channels
+func (c *Config) SpeakerChannels() int {
+	if c.InputFile != "" {
+		return 1 + len(c.ExtraSpeakerChannels)
+	}
+	return 1 + len(c.ExtraSpeakerFiles)
+}
+
+// MultiChannel reports whether more than one mic or speaker channel is configured
+func (c *Config) MultiChannel() bool {
+	return c.MicChannels() > 1 || c.SpeakerChannels() > 1
+}
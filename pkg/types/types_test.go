@@ -0,0 +1,45 @@
+package types
+
+import "testing"
+
+func TestConfig_SpeakerDelaySamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   int
+	}{
+		{
+			name:   "no delay",
+			config: &Config{SampleRate: 16000, FrameSize: 320},
+			want:   0,
+		},
+		{
+			name:   "previous speaker frame",
+			config: &Config{SampleRate: 16000, FrameSize: 320, UsePrevSpeaker: true},
+			want:   320,
+		},
+		{
+			name:   "delay in samples",
+			config: &Config{SampleRate: 16000, FrameSize: 320, SpeakerDelay: 100},
+			want:   100,
+		},
+		{
+			name:   "delay in milliseconds",
+			config: &Config{SampleRate: 16000, FrameSize: 320, SpeakerDelay: 100, SpeakerDelayMs: 12.5},
+			want:   200,
+		},
+		{
+			name:   "advance in milliseconds",
+			config: &Config{SampleRate: 8000, FrameSize: 160, SpeakerDelayMs: -30},
+			want:   -240,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.SpeakerDelaySamples(); got != tt.want {
+				t.Errorf("Config.SpeakerDelaySamples() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}